@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// apiChange is an exported identifier removed or changed between two versions.
+type apiChange struct {
+	// pkg is the package directory relative to the module root, empty for the root package.
+	pkg string
+	// name is Name for package level identifiers, Type.Member for fields and methods.
+	name string
+	old  string
+	// new is empty when the identifier was removed.
+	new string
+	// used reports whether our packages reference the identifier.
+	used bool
+}
+
+func (c *apiChange) kind() string {
+	if c.new == "" {
+		return "removed"
+	}
+
+	return "changed"
+}
+
+func (c *apiChange) impact() string {
+	if c.used {
+		return "breaking for you"
+	}
+
+	return "breaking but unused"
+}
+
+// apiDiff computes the exported API removed or changed between the current and
+// the new version of a module and marks the changes our module at root uses.
+func apiDiff(root string, v version) ([]apiChange, error) {
+	oldDir, err := downloadDir(v.mod, v.old)
+	if err != nil {
+		return nil, err
+	}
+
+	newDir, err := downloadDir(v.newPath(), v.new)
+	if err != nil {
+		return nil, err
+	}
+
+	oldAPI, err := moduleAPI(oldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	newAPI, err := moduleAPI(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := collectUsage(root, v.path, oldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]apiChange, 0)
+	for pkg, decls := range oldAPI {
+		for name, sig := range decls {
+			newSig, ok := newAPI[pkg][name]
+			if ok && newSig == sig {
+				continue
+			}
+
+			changes = append(changes, apiChange{
+				pkg:  pkg,
+				name: name,
+				old:  sig,
+				new:  newSig,
+				used: usage.uses(pkg, name),
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].used != changes[j].used {
+			return changes[i].used
+		}
+		if changes[i].pkg != changes[j].pkg {
+			return changes[i].pkg < changes[j].pkg
+		}
+		return changes[i].name < changes[j].name
+	})
+
+	return changes, nil
+}
+
+// apiSummaries returns the api summary of each upgrade, the modules are downloaded in parallel.
+func apiSummaries(root string, versions []version) []string {
+	summaries := make([]string, len(versions))
+	parallel(len(versions), func(i int) {
+		summaries[i] = "unknown"
+		if changes, err := apiDiff(root, versions[i]); err == nil {
+			summaries[i] = apiSummary(changes)
+		}
+	})
+
+	return summaries
+}
+
+// apiSummary sums up the changes in a few words, for the list table.
+func apiSummary(changes []apiChange) string {
+	used := 0
+	for _, c := range changes {
+		if c.used {
+			used++
+		}
+	}
+
+	if len(changes) == 0 {
+		return "no breaking changes"
+	}
+
+	return fmt.Sprintf("%d breaking for you, %d unused", used, len(changes)-used)
+}
+
+// moduleAPI returns the exported API of every package of the module in dir,
+// indexed by package directory and identifier, see apiChange.
+// only the files built for the current GOOS and GOARCH are read, so the
+// declarations of a package depend on its build constraints, not on the walk order.
+func moduleAPI(dir string) (map[string]map[string]string, error) {
+	api := make(map[string]map[string]string)
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != dir && (skipDir(d.Name()) || d.Name() == "internal") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		if ok, err := build.Default.MatchFile(filepath.Dir(path), d.Name()); err != nil || !ok {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil || f.Name.Name == "main" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		pkg := filepath.ToSlash(rel)
+		if pkg == "." {
+			pkg = ""
+		}
+
+		if api[pkg] == nil {
+			api[pkg] = make(map[string]string)
+		}
+		fileAPI(fset, f, api[pkg])
+
+		return nil
+	})
+
+	return api, err
+}
+
+// fileAPI adds the exported declarations of a file to decls.
+func fileAPI(fset *token.FileSet, f *ast.File, decls map[string]string) {
+	str := func(n ast.Node) string {
+		var buf bytes.Buffer
+		_ = printer.Fprint(&buf, fset, n)
+		return buf.String()
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+
+			if d.Recv == nil {
+				decls[d.Name.Name] = "func" + funcSig(str, d.Type)
+				continue
+			}
+
+			if recv := recvName(d.Recv.List[0].Type); ast.IsExported(recv) {
+				decls[recv+"."+d.Name.Name] = "func" + funcSig(str, d.Type)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						typeAPI(str, s, decls)
+					}
+				case *ast.ValueSpec:
+					typ := ""
+					if s.Type != nil {
+						typ = " " + str(s.Type)
+					}
+
+					for _, name := range s.Names {
+						if name.IsExported() {
+							decls[name.Name] = d.Tok.String() + typ
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// typeAPI adds a type, its exported fields and interface methods to decls.
+func typeAPI(str func(ast.Node) string, s *ast.TypeSpec, decls map[string]string) {
+	name := s.Name.Name
+
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		decls[name] = "struct"
+		for _, field := range t.Fields.List {
+			typ := str(field.Type)
+			if len(field.Names) == 0 {
+				if embedded := recvName(field.Type); ast.IsExported(embedded) {
+					decls[name+"."+embedded] = typ
+				}
+				continue
+			}
+
+			for _, n := range field.Names {
+				if n.IsExported() {
+					decls[name+"."+n.Name] = typ
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		decls[name] = "interface"
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				decls[name+"."+str(m.Type)] = "embedded"
+				continue
+			}
+
+			if ft, ok := m.Type.(*ast.FuncType); ok && m.Names[0].IsExported() {
+				decls[name+"."+m.Names[0].Name] = "func" + funcSig(str, ft)
+			}
+		}
+	default:
+		if s.Assign.IsValid() {
+			decls[name] = "= " + str(s.Type)
+		} else {
+			decls[name] = str(s.Type)
+		}
+	}
+}
+
+// funcSig prints the parameter and result types of a function, without names,
+// so renaming a parameter is not a change.
+func funcSig(str func(ast.Node) string, ft *ast.FuncType) string {
+	fields := func(list *ast.FieldList) []string {
+		types := make([]string, 0)
+		if list == nil {
+			return types
+		}
+
+		for _, f := range list.List {
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+
+			typ := str(f.Type)
+			for i := 0; i < n; i++ {
+				types = append(types, typ)
+			}
+		}
+
+		return types
+	}
+
+	sig := "(" + strings.Join(fields(ft.Params), ", ") + ")"
+	switch results := fields(ft.Results); len(results) {
+	case 0:
+	case 1:
+		sig += " " + results[0]
+	default:
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+
+	return sig
+}
+
+// recvName returns the type name of a receiver or an embedded field, e.g. *T[K] => T.
+func recvName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return recvName(e.X)
+	case *ast.IndexExpr:
+		return recvName(e.X)
+	case *ast.IndexListExpr:
+		return recvName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	}
+
+	return ""
+}
+
+// apiUsage is what our packages reference from a module.
+type apiUsage struct {
+	// idents are the qualified identifiers pkg.Name, by package directory.
+	idents map[string]map[string]bool
+	// members are the selector names used in files importing the module,
+	// fields and methods can't be told apart from their types without type checking.
+	members map[string]bool
+}
+
+func (u *apiUsage) uses(pkg, name string) bool {
+	if i := strings.Index(name, "."); i >= 0 {
+		_, imported := u.idents[pkg]
+		return imported && u.members[name[i+1:]]
+	}
+
+	return u.idents[pkg][name]
+}
+
+// collectUsage collects what the go files of the module at root use from the
+// module with the given prefix, oldDir is the current version in the module cache.
+// nested modules are skipped, they have their own requirements.
+func collectUsage(root, modprefix, oldDir string) (*apiUsage, error) {
+	u := &apiUsage{idents: make(map[string]map[string]bool), members: make(map[string]bool)}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+
+			if skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+
+			if _, err := os.Lstat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			return nil
+		}
+
+		u.file(f, modprefix, oldDir)
+
+		return nil
+	})
+
+	return u, err
+}
+
+func (u *apiUsage) file(f *ast.File, modprefix, oldDir string) {
+	// local package name => package directory in the module.
+	locals := make(map[string]string)
+	imported := false
+	for _, i := range f.Imports {
+		path := strings.Trim(i.Path.Value, "`\"")
+		_, pkg, ok := splitPath(modprefix, path)
+		if !ok {
+			continue
+		}
+
+		imported = true
+		if u.idents[pkg] == nil {
+			u.idents[pkg] = make(map[string]bool)
+		}
+
+		name := ""
+		if i.Name != nil {
+			name = i.Name.Name
+		} else if name = pkgNameIn(filepath.Join(oldDir, filepath.FromSlash(pkg))); name == "" {
+			name = assumedName(path)
+		}
+
+		switch name {
+		case "_":
+		case ".":
+			for _, ident := range f.Unresolved {
+				u.idents[pkg][ident.Name] = true
+			}
+		default:
+			locals[name] = pkg
+		}
+	}
+
+	if !imported {
+		return
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+			if pkg, ok := locals[x.Name]; ok {
+				u.idents[pkg][sel.Sel.Name] = true
+				return true
+			}
+		}
+
+		u.members[sel.Sel.Name] = true
+
+		return true
+	})
+}
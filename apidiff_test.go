@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleAPI(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"foo.go":          "package foo\n\nconst A = 1\n\ntype T struct {\n\tX int\n\ty int\n}\n\nfunc (t *T) M(a, b string) error { return nil }\n\nfunc F(name string) {}\n\nfunc g() {}\n",
+		"foo_test.go":     "package foo\n\nfunc TestOnly() {}\n",
+		"foo_plan9.go":    "package foo\n\nfunc F(n int) {}\n",
+		"zz_ignored.go":   "//go:build ignore\n\npackage foo\n\nfunc F(n int) {}\n",
+		"internal/i/i.go": "package i\n\nfunc I() {}\n",
+		"sub/sub.go":      "package sub\n\ntype I interface {\n\tDo(int) bool\n}\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+
+	api, err := moduleAPI(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"": {
+			"A":   "const",
+			"T":   "struct",
+			"T.X": "int",
+			"T.M": "func(string, string) error",
+			"F":   "func(string)",
+		},
+		"sub": {
+			"I":    "interface",
+			"I.Do": "func(int) bool",
+		},
+	}, api)
+}
+
+func TestCollectUsage(t *testing.T) {
+	root := t.TempDir()
+	src := "package p\n\nimport (\n\tf \"example.com/foo\"\n\t\"example.com/foo/sub\"\n)\n\nfunc P(i sub.I) { f.F(\"\"); var t f.T; t.M(\"\", \"\") }\n"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "p.go"), []byte(src), 0644))
+
+	// a nested module has its own requirements.
+	nested := filepath.Join(root, "nested")
+	assert.Nil(t, os.MkdirAll(nested, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(nested, "go.mod"), []byte("module example.com/p/nested\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(nested, "n.go"), []byte("package n\n\nimport \"example.com/foo\"\n\nvar _ = foo.A\n"), 0644))
+
+	u, err := collectUsage(root, "example.com/foo", t.TempDir())
+	assert.Nil(t, err)
+
+	assert.True(t, u.uses("", "F"))
+	assert.True(t, u.uses("", "T"))
+	assert.True(t, u.uses("", "T.M"))
+	assert.True(t, u.uses("sub", "I"))
+	assert.False(t, u.uses("", "A"))
+	assert.False(t, u.uses("", "T.X"))
+	assert.False(t, u.uses("sub", "I.Do"))
+	assert.False(t, u.uses("other", "F"))
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
+)
+
+// binary is a go executable and its main module.
+type binary struct {
+	// name is the file name of the executable.
+	name string
+	file string
+	// dir is the directory the binary was found in.
+	dir string
+	// pkg is the package path of the main package.
+	pkg string
+	// goVersion is the go release the binary was built with.
+	goVersion string
+	kind      binaryKind
+	settings  buildSettings
+	version
+}
+
+// binaryKind tells whether a binary can be reinstalled with go install.
+type binaryKind string
+
+const (
+	kindInstallable binaryKind = "installable"
+	// kindDevel is built from a local checkout, its version is (devel).
+	kindDevel binaryKind = "devel"
+	// kindReplaced is built with replace directives, which go install refuses.
+	kindReplaced binaryKind = "replaced"
+	// kindStdlibTool is a command of the go distribution, like gofmt.
+	kindStdlibTool binaryKind = "stdlib-tool"
+	// kindRenamed is named differently than go install names it, e.g. built with go build -o.
+	kindRenamed binaryKind = "renamed"
+	// kindReadOnly is in a directory found by --path-scan which the user can't write to.
+	kindReadOnly binaryKind = "read-only"
+)
+
+// binaryPath returns the binary or the directory of binaries to check.
+func binaryPath(ctx *cli.Context) string {
+	if ctx.Bool("global") {
+		return filepath.Join(os.Getenv("GOPATH"), "bin")
+	}
+
+	if fp := ctx.Args().First(); fp != "" {
+		return fp
+	}
+
+	return "."
+}
+
+// buildSettings are the settings a binary was built with.
+type buildSettings struct {
+	tags     string
+	ldflags  string
+	cgo      string
+	goos     string
+	goarch   string
+	trimpath bool
+	// revision is the VCS revision of the checkout, modified whether it had uncommitted changes.
+	revision string
+	modified bool
+}
+
+// readBinaries reads the build info of the go executables at fp, a file or a directory
+// walked like go version does. the files which are not go executables are skipped.
+func readBinaries(fp string) ([]binary, error) {
+	st, err := os.Stat(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !st.IsDir() {
+		info, err := buildinfo.ReadFile(fp)
+		var perr *fs.PathError
+		if errors.As(err, &perr) {
+			return nil, err
+		}
+
+		// like in a directory, a file which is not a go executable is skipped.
+		if err != nil {
+			return []binary{}, nil
+		}
+
+		return []binary{newBinary(fp, info)}, nil
+	}
+
+	bins := make([]binary, 0)
+	err = filepath.WalkDir(fp, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != fp && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		if info, err := buildinfo.ReadFile(path); err == nil {
+			bins = append(bins, newBinary(path, info))
+		}
+
+		return nil
+	})
+
+	return bins, err
+}
+
+// newBinary takes the main module and the build settings of the build info.
+func newBinary(file string, info *debug.BuildInfo) binary {
+	b := binary{
+		name:      filepath.Base(file),
+		file:      file,
+		dir:       filepath.Dir(file),
+		pkg:       info.Path,
+		goVersion: info.GoVersion,
+		version: version{
+			path: modPrefix(info.Main.Path),
+			mod:  info.Main.Path,
+			old:  info.Main.Version,
+		},
+	}
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "-tags":
+			b.settings.tags = s.Value
+		case "-ldflags":
+			b.settings.ldflags = s.Value
+		case "-trimpath":
+			b.settings.trimpath = s.Value == "true"
+		case "CGO_ENABLED":
+			b.settings.cgo = s.Value
+		case "GOOS":
+			b.settings.goos = s.Value
+		case "GOARCH":
+			b.settings.goarch = s.Value
+		case "vcs.revision":
+			b.settings.revision = s.Value
+		case "vcs.modified":
+			b.settings.modified = s.Value == "true"
+		}
+	}
+
+	b.kind = classifyBinary(info)
+
+	// go install would write another file next to it and leave this one as it is.
+	if (b.kind == kindInstallable || b.kind == kindDevel) && b.mod != "" && exeName(b.file) != installName(b.pkg) {
+		b.kind = kindRenamed
+	}
+
+	return b
+}
+
+// installName is the name go install gives to the executable of the main package pkg:
+// the last element of its path, or the one before a major version suffix like /v2.
+func installName(pkg string) string {
+	elem := path.Base(pkg)
+	if isMajor(elem) && elem != "v0" && elem != "v1" && elem[1] != '0' {
+		elem = path.Base(path.Dir(pkg))
+	}
+
+	return elem
+}
+
+// exeName is the name of the executable file, without the .exe of windows.
+func exeName(file string) string {
+	name := filepath.Base(file)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, ".exe")
+	}
+
+	return name
+}
+
+// classifyBinary tells how a binary was built from its build info.
+func classifyBinary(info *debug.BuildInfo) binaryKind {
+	if info.Main.Path == "" {
+		if strings.HasPrefix(info.Path, "cmd/") {
+			return kindStdlibTool
+		}
+		// built from files, like go build main.go.
+		return kindDevel
+	}
+
+	if info.Main.Replace != nil {
+		return kindReplaced
+	}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			return kindReplaced
+		}
+	}
+
+	// go 1.24 stamps the version of a local checkout, +dirty with uncommitted changes.
+	if !semver.IsValid(info.Main.Version) || semver.Build(info.Main.Version) == "+dirty" {
+		return kindDevel
+	}
+
+	return kindInstallable
+}
+
+// rebuildable reports whether a devel binary can be rebuilt from its recorded revision.
+func (b *binary) rebuildable() bool {
+	return b.kind == kindDevel && b.mod != "" && b.settings.revision != "" && !b.settings.modified
+}
+
+// skipReason explains why the binary is not upgraded.
+func (b *binary) skipReason() string {
+	switch b.kind {
+	case kindStdlibTool:
+		return "part of the go distribution, upgrade go instead"
+	case kindReplaced:
+		return "built with replace directives, go install can't reproduce it"
+	case kindReadOnly:
+		return "you can't write to its directory, go install would fail"
+	case kindRenamed:
+		return "go install names it " + installName(b.pkg) + ", it was built under another name"
+	case kindDevel:
+		switch {
+		case b.mod == "":
+			return "built from source files outside of a module"
+		case b.settings.revision == "":
+			return "built from a local checkout without a recorded VCS revision"
+		case b.settings.modified:
+			return "built from a local checkout with uncommitted changes"
+		}
+		return "built from a local checkout, --rebuild-devel rebuilds it from revision " + shortRevision(b.settings.revision)
+	}
+
+	return ""
+}
+
+func shortRevision(rev string) string {
+	if len(rev) > 12 {
+		return rev[:12]
+	}
+
+	return rev
+}
+
+// splitBinaries separates the binaries go install can upgrade from the others.
+// with rebuild, the devel binaries with a recorded revision are rebuilt from it.
+func splitBinaries(bins []binary, rebuild bool) (installable, rebuilds, skipped []binary) {
+	for _, b := range bins {
+		switch {
+		case b.kind == kindInstallable:
+			installable = append(installable, b)
+		case rebuild && b.rebuildable():
+			rebuilds = append(rebuilds, b)
+		default:
+			skipped = append(skipped, b)
+		}
+	}
+
+	return
+}
+
+// filterBinaries keeps the binaries whose name or main module matches the pattern.
+func filterBinaries(bins []binary, pattern string) ([]binary, error) {
+	if pattern == "" {
+		return bins, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]binary, 0, len(bins))
+	for _, b := range bins {
+		if re.MatchString(b.name) || re.MatchString(b.mod) {
+			kept = append(kept, b)
+		}
+	}
+
+	return kept, nil
+}
+
+// outdatedBinaries looks up the latest version of the main module of each binary, a few
+// at a time, across major versions unless safe, and returns the outdated ones.
+// the binaries whose versions can't be looked up are not outdated, lookups tells why.
+func outdatedBinaries(bins []binary, cached, stable, safe bool) (outdated []binary, lookups []error, err error) {
+	s := spinner.New(spinner.CharSets[36], 100*time.Millisecond)
+	s.Prefix = "Checking... Please wait.  "
+	if err := s.Color("cyan"); err != nil {
+		return nil, nil, err
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	outdated = make([]binary, 0, len(bins))
+	mu := sync.Mutex{}
+	fail := func(b binary, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		lookups = append(lookups, fmt.Errorf("%s (%s): %v", b.name, b.mod, err))
+	}
+
+	// there is nothing to compare with the binaries go install can't reproduce.
+	installable := make([]binary, 0, len(bins))
+	for _, b := range bins {
+		if b.kind == kindInstallable {
+			installable = append(installable, b)
+		}
+	}
+
+	parallel(len(installable), func(i int) {
+		b := installable[i]
+		if safe {
+			mod, ok, err := query(b.mod, cached)
+			if err == nil && !ok {
+				err = fmt.Errorf("module not found: %s", b.mod)
+			}
+			if err != nil {
+				fail(b, err)
+				return
+			}
+			b.new = mod.maxVersion(semver.Major(b.old)+".", stable)
+		} else {
+			mod, err := latest(b.mod, cached)
+			if err != nil {
+				fail(b, err)
+				return
+			}
+			b.new = mod.maxVersion("", stable)
+		}
+
+		if b.new == "" || semver.Compare(b.old, b.new) >= 0 {
+			return
+		}
+
+		mu.Lock()
+		outdated = append(outdated, b)
+		mu.Unlock()
+	})
+
+	// new major versions are a group of their own, after the other binaries.
+	sort.Slice(outdated, func(i, j int) bool {
+		if outdated[i].majorChanged() != outdated[j].majorChanged() {
+			return outdated[j].majorChanged()
+		}
+		return outdated[i].name < outdated[j].name
+	})
+
+	// the lookups end in any order.
+	sort.Slice(lookups, func(i, j int) bool {
+		return lookups[i].Error() < lookups[j].Error()
+	})
+
+	return outdated, lookups, nil
+}
+
+// installPath is the main package at the new version, under the module path of its major version.
+// a devel binary is rebuilt from its revision.
+func (b *binary) installPath() string {
+	if b.kind == kindDevel {
+		return b.pkg + "@" + b.settings.revision
+	}
+
+	pkgdir := strings.TrimPrefix(strings.TrimPrefix(b.pkg, b.mod), "/")
+	return joinPath(b.path, b.new, pkgdir) + "@" + b.new
+}
+
+// installArgs are the arguments of go install reproducing the build settings of the
+// binary, unless reset, and the environment installing it into its own directory.
+// the -X flags stamping the old version are dropped.
+func (b *binary) installArgs(reset bool) (args []string, env []string) {
+	args = []string{"install"}
+	if dir, err := filepath.Abs(filepath.Dir(b.file)); err == nil {
+		env = append(env, "GOBIN="+dir)
+	}
+
+	if !reset {
+		if b.settings.tags != "" {
+			args = append(args, "-tags", b.settings.tags)
+		}
+		if ldflags := dropVersionFlags(b.settings.ldflags, b.old); ldflags != "" {
+			args = append(args, "-ldflags", ldflags)
+		}
+		if b.settings.trimpath {
+			args = append(args, "-trimpath")
+		}
+		if b.settings.cgo != "" {
+			env = append(env, "CGO_ENABLED="+b.settings.cgo)
+		}
+	}
+
+	return append(args, b.installPath()), env
+}
+
+// dropVersionFlags removes the -X flags setting a variable to the version ver, with or
+// without its v, from ldflags. the new binary would report the old version.
+func dropVersionFlags(ldflags, ver string) string {
+	ver = strings.TrimPrefix(ver, "v")
+	if ver == "" || strings.ContainsAny(ldflags, `'"`) {
+		// the quoted flags are kept as they are.
+		return ldflags
+	}
+
+	isVersion := func(def string) bool {
+		i := strings.Index(def, "=")
+		return i >= 0 && strings.TrimPrefix(def[i+1:], "v") == ver
+	}
+
+	flags := strings.Fields(ldflags)
+	kept := make([]string, 0, len(flags))
+	for i := 0; i < len(flags); i++ {
+		switch f := flags[i]; {
+		case (f == "-X" || f == "--X") && i+1 < len(flags):
+			if isVersion(flags[i+1]) {
+				i++
+				continue
+			}
+			kept = append(kept, f, flags[i+1])
+			i++
+		case strings.HasPrefix(f, "-X=") || strings.HasPrefix(f, "--X="):
+			if !isVersion(f[strings.Index(f, "=")+1:]) {
+				kept = append(kept, f)
+			}
+		default:
+			kept = append(kept, f)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// installCommand is the go install of the binary, as typed in a shell.
+func (b *binary) installCommand(reset bool) string {
+	args, env := b.installArgs(reset)
+
+	words := make([]string, 0, len(env)+len(args)+1)
+	for _, e := range env {
+		words = append(words, shellQuote(e))
+	}
+	words = append(words, "go")
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installResult is the outcome of the go install of a binary.
+type installResult struct {
+	bin    binary
+	output []byte
+	err    error
+}
+
+// installBinaries runs go install for each binary, a few at a time, and captures its output.
+// the binaries are built like they were, unless reset, into their own directory.
+func installBinaries(bins []binary, reset bool) ([]installResult, error) {
+	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
+	s.Prefix = "Updating... Please wait. "
+	if err := s.Color("cyan"); err != nil {
+		return nil, err
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	results := make([]installResult, len(bins))
+	parallel(len(bins), func(i int) {
+		args, env := bins[i].installArgs(reset)
+		cmd := exec.Command("go", args...)
+		cmd.Env = append(os.Environ(), env...)
+
+		output, err := cmd.CombinedOutput()
+		results[i] = installResult{bin: bins[i], output: output, err: err}
+	})
+
+	return results, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildHello builds a tiny binary of the module example.com/hello/v2 at out.
+func buildHello(t *testing.T, out string) bool {
+	src := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/hello/v2\n\ngo 1.22\n"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "cmd", "hello"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "cmd", "hello", "main.go"), []byte("package main\n\nvar v string\n\nfunc main() { println(v) }\n"), 0644))
+
+	cmd := exec.Command("go", "build", "-tags", "netgo", "-ldflags", "-X main.v=1", "-o", out, "./cmd/hello")
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOFLAGS=")
+	output, err := cmd.CombinedOutput()
+
+	return assert.Nil(t, err, string(output))
+}
+
+func TestReadBinaries(t *testing.T) {
+	bin := t.TempDir()
+	if !buildHello(t, filepath.Join(bin, "hello")) {
+		return
+	}
+
+	// not go executables, skipped quietly.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(bin, "notes.txt"), []byte("hello"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(bin, "script.sh"), []byte("#!/bin/sh\necho hello\n"), 0755))
+
+	want := binary{
+		name:      "hello",
+		file:      filepath.Join(bin, "hello"),
+		dir:       bin,
+		pkg:       "example.com/hello/v2/cmd/hello",
+		goVersion: runtime.Version(),
+		kind:      kindDevel,
+		settings: buildSettings{
+			tags:    "netgo",
+			ldflags: "-X main.v=1",
+			cgo:     "0",
+			goos:    runtime.GOOS,
+			goarch:  runtime.GOARCH,
+		},
+		version: version{path: "example.com/hello", mod: "example.com/hello/v2", old: "(devel)"},
+	}
+
+	bins, err := readBinaries(bin)
+	assert.Nil(t, err)
+	assert.Equal(t, []binary{want}, bins)
+
+	bins, err = readBinaries(filepath.Join(bin, "hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, []binary{want}, bins)
+
+	bins, err = readBinaries(filepath.Join(bin, "notes.txt"))
+	assert.Nil(t, err)
+	assert.Empty(t, bins)
+
+	bins, err = readBinaries(filepath.Join(bin, "script.sh"))
+	assert.Nil(t, err)
+	assert.Empty(t, bins)
+
+	_, err = readBinaries(filepath.Join(bin, "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestFilterBinaries(t *testing.T) {
+	bins := []binary{
+		{name: "golangci-lint", version: version{mod: "github.com/golangci/golangci-lint"}},
+		{name: "gopls", version: version{mod: "golang.org/x/tools/gopls"}},
+		{name: "stringer", version: version{mod: "golang.org/x/tools"}},
+	}
+
+	kept, err := filterBinaries(bins, "")
+	assert.Nil(t, err)
+	assert.Equal(t, bins, kept)
+
+	kept, err = filterBinaries(bins, "^golang.org/x/tools$|lint")
+	assert.Nil(t, err)
+	assert.Equal(t, []binary{bins[0], bins[2]}, kept)
+
+	_, err = filterBinaries(bins, "(")
+	assert.NotNil(t, err)
+}
+
+func TestInstallPath(t *testing.T) {
+	cases := []struct {
+		bin  binary
+		want string
+	}{
+		{
+			binary{pkg: "golang.org/x/tools/gopls", version: version{path: "golang.org/x/tools/gopls", mod: "golang.org/x/tools/gopls", new: "v0.15.0"}},
+			"golang.org/x/tools/gopls@v0.15.0",
+		},
+		{
+			binary{pkg: "github.com/golangci/golangci-lint/cmd/golangci-lint", version: version{path: "github.com/golangci/golangci-lint", mod: "github.com/golangci/golangci-lint", new: "v2.1.0"}},
+			"github.com/golangci/golangci-lint/v2/cmd/golangci-lint@v2.1.0",
+		},
+		{
+			binary{pkg: "example.com/tool/v2/cmd/tool", version: version{path: "example.com/tool", mod: "example.com/tool/v2", new: "v2.3.0"}},
+			"example.com/tool/v2/cmd/tool@v2.3.0",
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.bin.installPath())
+	}
+}
+
+func TestInstallArgs(t *testing.T) {
+	b := binary{
+		file: "/go/bin/hello",
+		pkg:  "example.com/hello/cmd/hello",
+		settings: buildSettings{
+			tags:     "netgo,osusergo",
+			ldflags:  "-s -w -X main.version=1.2.0",
+			cgo:      "0",
+			goos:     "linux",
+			goarch:   "amd64",
+			trimpath: true,
+		},
+		version: version{path: "example.com/hello", mod: "example.com/hello", old: "v1.2.0", new: "v1.3.0"},
+	}
+
+	args, env := b.installArgs(false)
+	// the new binary must not report the old version.
+	assert.Equal(t, []string{"install", "-tags", "netgo,osusergo", "-ldflags", "-s -w", "-trimpath", "example.com/hello/cmd/hello@v1.3.0"}, args)
+	assert.Equal(t, []string{"GOBIN=" + filepath.FromSlash("/go/bin"), "CGO_ENABLED=0"}, env)
+	assert.Equal(t, "GOBIN=/go/bin CGO_ENABLED=0 go install -tags netgo,osusergo -ldflags '-s -w' -trimpath example.com/hello/cmd/hello@v1.3.0", b.installCommand(false))
+
+	args, env = b.installArgs(true)
+	assert.Equal(t, []string{"install", "example.com/hello/cmd/hello@v1.3.0"}, args)
+	assert.Equal(t, []string{"GOBIN=" + filepath.FromSlash("/go/bin")}, env)
+}
+
+func TestDropVersionFlags(t *testing.T) {
+	tests := []struct {
+		ldflags, ver, want string
+	}{
+		{"-s -w -X main.version=1.2.0", "v1.2.0", "-s -w"},
+		{"-X main.version=v1.2.0 -X main.commit=abc", "v1.2.0", "-X main.commit=abc"},
+		{"-X=main.version=1.2.0 -s", "v1.2.0", "-s"},
+		{"-X main.version=1.2.1", "v1.2.0", "-X main.version=1.2.1"},
+		{"-X 'main.version=1.2.0'", "v1.2.0", "-X 'main.version=1.2.0'"},
+		{"", "v1.2.0", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, dropVersionFlags(tt.ldflags, tt.ver), tt.ldflags)
+	}
+}
+
+func TestInstallName(t *testing.T) {
+	assert.Equal(t, "tool", installName("example.com/tool"))
+	assert.Equal(t, "tool", installName("example.com/tool/v2"))
+	assert.Equal(t, "hello", installName("example.com/tool/v2/cmd/hello"))
+	assert.Equal(t, "v1", installName("example.com/tool/v1"))
+	assert.Equal(t, "yaml.v3", installName("gopkg.in/yaml.v3"))
+
+	// built with go build -o, go install would write another file.
+	info := &debug.BuildInfo{Path: "example.com/tool/cmd/tool", Main: debug.Module{Path: "example.com/tool", Version: "v1.2.0"}}
+	b := newBinary("/go/bin/mytool", info)
+	assert.Equal(t, kindRenamed, b.kind)
+	assert.Equal(t, "go install names it tool, it was built under another name", b.skipReason())
+
+	b = newBinary("/go/bin/tool", info)
+	assert.Equal(t, kindInstallable, b.kind)
+}
+
+func TestOutdatedBinaries(t *testing.T) {
+	files := map[string]string{
+		"/example.com/lint/@v/list":    "v1.0.0\nv1.1.0\n",
+		"/example.com/lint/v2/@v/list": "v2.0.0\nv2.1.0\n",
+		"/example.com/fmt/@v/list":     "v0.1.0\nv0.2.0\nv0.3.0-rc.1\n",
+		"/example.com/gen/@v/list":     "v1.0.0\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.Error(w, "not found: "+r.URL.Path, http.StatusGone)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	t.Setenv("GOPROXY", srv.URL)
+
+	bins := []binary{
+		{name: "lint", pkg: "example.com/lint/cmd/lint", kind: kindInstallable, version: version{path: "example.com/lint", mod: "example.com/lint", old: "v1.0.0"}},
+		{name: "fmt", pkg: "example.com/fmt", kind: kindInstallable, version: version{path: "example.com/fmt", mod: "example.com/fmt", old: "v0.1.0"}},
+		{name: "gen", pkg: "example.com/gen", kind: kindInstallable, version: version{path: "example.com/gen", mod: "example.com/gen", old: "v1.0.0"}},
+		{name: "local", pkg: "example.com/local", kind: kindDevel, version: version{path: "example.com/local", mod: "example.com/local", old: "(devel)"}},
+	}
+
+	got, lookups, err := outdatedBinaries(bins, false, true, false)
+	assert.Nil(t, err)
+	assert.Empty(t, lookups)
+	if assert.Equal(t, 2, len(got)) {
+		// the new major version comes last.
+		assert.Equal(t, "example.com/fmt@v0.2.0", got[0].installPath())
+		assert.Equal(t, "example.com/lint/v2/cmd/lint@v2.1.0", got[1].installPath())
+		assert.True(t, got[1].majorChanged())
+	}
+
+	got, lookups, err = outdatedBinaries(bins, false, true, true)
+	assert.Nil(t, err)
+	assert.Empty(t, lookups)
+	if assert.Equal(t, 2, len(got)) {
+		assert.Equal(t, "example.com/fmt@v0.2.0", got[0].installPath())
+		assert.Equal(t, "example.com/lint/cmd/lint@v1.1.0", got[1].installPath())
+	}
+
+	// a binary whose module can't be looked up is reported, not taken as up to date.
+	gone := binary{name: "gone", pkg: "example.com/gone", kind: kindInstallable, version: version{path: "example.com/gone", mod: "example.com/gone", old: "v1.0.0"}}
+	for _, safe := range []bool{false, true} {
+		got, lookups, err = outdatedBinaries([]binary{gone}, false, true, safe)
+		assert.Nil(t, err)
+		assert.Empty(t, got)
+		if assert.Len(t, lookups, 1) {
+			assert.Contains(t, lookups[0].Error(), "gone (example.com/gone): ")
+		}
+	}
+}
+
+func TestClassifyBinary(t *testing.T) {
+	main := debug.Module{Path: "example.com/tool", Version: "v1.2.0"}
+	vcs := []debug.BuildSetting{{Key: "vcs", Value: "git"}, {Key: "vcs.revision", Value: "0123456789abcdef0123"}}
+
+	tests := []struct {
+		name        string
+		info        debug.BuildInfo
+		kind        binaryKind
+		rebuildable bool
+	}{
+		{"installable", debug.BuildInfo{Path: "example.com/tool/cmd/tool", Main: main}, kindInstallable, false},
+		{"gofmt", debug.BuildInfo{Path: "cmd/gofmt"}, kindStdlibTool, false},
+		{"files", debug.BuildInfo{Path: "command-line-arguments"}, kindDevel, false},
+		{
+			"replaced",
+			debug.BuildInfo{Path: "example.com/tool", Main: main, Deps: []*debug.Module{
+				{Path: "example.com/dep", Version: "v1.0.0", Replace: &debug.Module{Path: "../dep", Version: "(devel)"}},
+			}},
+			kindReplaced, false,
+		},
+		{"dirty", debug.BuildInfo{Path: "example.com/tool", Main: debug.Module{Path: "example.com/tool", Version: "v0.0.0-20240102030405-0123456789ab+dirty"}}, kindDevel, false},
+		{"devel", debug.BuildInfo{Path: "example.com/tool", Main: debug.Module{Path: "example.com/tool", Version: "(devel)"}}, kindDevel, false},
+		{"devel with revision", debug.BuildInfo{Path: "example.com/tool", Main: debug.Module{Path: "example.com/tool", Version: "(devel)"}, Settings: vcs}, kindDevel, true},
+		{
+			"devel modified",
+			debug.BuildInfo{Path: "example.com/tool", Main: debug.Module{Path: "example.com/tool", Version: "(devel)"}, Settings: append(vcs, debug.BuildSetting{Key: "vcs.modified", Value: "true"})},
+			kindDevel, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			b := newBinary("/go/bin/tool", &info)
+			assert.Equal(t, tt.kind, b.kind)
+			assert.Equal(t, tt.rebuildable, b.rebuildable())
+			if tt.kind != kindInstallable {
+				assert.NotEmpty(t, b.skipReason())
+			}
+		})
+	}
+
+	b := newBinary("/go/bin/tool", &debug.BuildInfo{Path: "example.com/tool/v2/cmd/tool", Main: debug.Module{Path: "example.com/tool/v2", Version: "(devel)"}, Settings: vcs})
+	assert.Equal(t, "example.com/tool/v2/cmd/tool@0123456789abcdef0123", b.installPath())
+	installable, rebuilds, skipped := splitBinaries([]binary{b}, false)
+	assert.Equal(t, 0, len(installable)+len(rebuilds))
+	assert.Equal(t, 1, len(skipped))
+	_, rebuilds, _ = splitBinaries([]binary{b}, true)
+	assert.Equal(t, 1, len(rebuilds))
+}
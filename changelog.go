@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"golang.org/x/mod/semver"
+)
+
+// changelogNames are the base names, without extension, of the files with release notes.
+var changelogNames = []string{"CHANGELOG", "CHANGES", "HISTORY", "RELEASES", "RELEASE_NOTES", "RELEASE-NOTES", "NEWS"}
+
+var changelogExts = []string{"", ".md", ".markdown", ".txt", ".rst"}
+
+// versionRe matches a version in a heading like "## [1.2.0] - 2022-01-01" or "v1.2 (beta)".
+var versionRe = regexp.MustCompile(`(?:^|[^\w.])v?(\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z][0-9A-Za-z.]*)?)\b`)
+
+// changelogFile returns the name of the changelog at the root of the module in dir.
+func changelogFile(dir string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, want := range changelogNames {
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+
+			ext := filepath.Ext(e.Name())
+			base := strings.TrimSuffix(e.Name(), ext)
+			if !strings.EqualFold(base, want) {
+				continue
+			}
+
+			for _, x := range changelogExts {
+				if strings.EqualFold(ext, x) {
+					return filepath.Join(dir, e.Name())
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// changelog returns the release notes between the current and the new version
+// of a module, taken from the changelog of the new version in its module zip.
+func changelog(v version) (string, error) {
+	dir, err := downloadDir(v.newPath(), v.new)
+	if err != nil {
+		return "", err
+	}
+
+	name := changelogFile(dir)
+	if name == "" {
+		return "", errNoChangelog
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	notes := changelogSections(string(data), v.old, v.new)
+	if notes == "" {
+		return "", errNoChangelog
+	}
+
+	return notes, nil
+}
+
+// heading is a heading line of a changelog.
+type heading struct {
+	// line is the index of the first line of the heading.
+	line  int
+	level int
+	// version is the canonical version in the title, if any.
+	version string
+}
+
+// changelogSections extracts the sections of the releases in (from, to] from a
+// changelog. a release section starts with a heading holding its version and
+// ends with the next heading of the same or a higher level.
+func changelogSections(text, from, to string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	headings := changelogHeadings(lines)
+
+	// releases are the headings of the level of the first versioned heading.
+	level := 0
+	for _, h := range headings {
+		if h.version != "" {
+			level = h.level
+			break
+		}
+	}
+
+	if level == 0 {
+		return ""
+	}
+
+	sections := make([]string, 0)
+	for i, h := range headings {
+		if h.level != level || h.version == "" {
+			continue
+		}
+
+		if semver.Compare(h.version, from) <= 0 || semver.Compare(h.version, to) > 0 {
+			continue
+		}
+
+		end := len(lines)
+		for _, next := range headings[i+1:] {
+			if next.level <= level {
+				end = next.line
+				break
+			}
+		}
+
+		sections = append(sections, strings.TrimSpace(strings.Join(lines[h.line:end], "\n")))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+// changelogHeadings finds the atx (# title) and setext (title underlined
+// with = or -) headings, skipping fenced code blocks.
+func changelogHeadings(lines []string) []heading {
+	headings := make([]heading, 0)
+	fenced := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+
+		if fenced || trimmed == "" {
+			continue
+		}
+
+		title, level := "", 0
+		switch {
+		case isATXHeading(line):
+			level = len(line) - len(strings.TrimLeft(line, "#"))
+			title = strings.TrimSpace(line[level:])
+		case isUnderline(line, '=') || isUnderline(line, '-') || strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			continue
+		case i+1 < len(lines) && isUnderline(lines[i+1], '='):
+			title, level = trimmed, 1
+		case i+1 < len(lines) && isUnderline(lines[i+1], '-'):
+			title, level = trimmed, 2
+		default:
+			continue
+		}
+
+		h := heading{line: i, level: level}
+		if m := versionRe.FindStringSubmatch(title); m != nil {
+			h.version = canonicalVersion(m[1])
+		}
+
+		headings = append(headings, h)
+	}
+
+	return headings
+}
+
+// isATXHeading reports whether the line is a heading like "## title", not "#123".
+func isATXHeading(line string) bool {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	return level >= 1 && level <= 6 && (len(line) == level || line[level] == ' ' || line[level] == '\t')
+}
+
+func isUnderline(line string, c byte) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 3 && strings.Trim(line, string(c)) == ""
+}
+
+// canonicalVersion turns 1.2 into v1.2.0, returns "" if it isn't a version.
+func canonicalVersion(s string) string {
+	return semver.Canonical("v" + s)
+}
+
+var (
+	mdLinkRe = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdBoldRe = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+)
+
+// renderMarkdown renders the markdown of release notes for the terminal.
+func renderMarkdown(text string) string {
+	heading := color.New(color.FgCyan, color.Bold)
+	code := color.New(color.FgYellow)
+
+	var sb strings.Builder
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+
+		switch {
+		case fenced:
+			line = code.Sprint("    " + line)
+		case isATXHeading(line):
+			line = heading.Sprint(mdLinkRe.ReplaceAllString(strings.TrimSpace(strings.TrimLeft(line, "#")), "$1"))
+		case isUnderline(line, '=') || isUnderline(line, '-'):
+			continue
+		default:
+			line = mdLinkRe.ReplaceAllString(line, "$1")
+			line = mdBoldRe.ReplaceAllStringFunc(line, func(s string) string {
+				return color.New(color.Bold).Sprint(strings.Trim(s, "*_"))
+			})
+
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			if rest := strings.TrimLeft(line, " "); strings.HasPrefix(rest, "- ") || strings.HasPrefix(rest, "* ") {
+				line = indent + "• " + rest[2:]
+			}
+		}
+
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// previewLines is the number of lines of release notes shown in the upgrade prompt.
+const previewLines = 15
+
+// changelogPreview returns the first lines of the release notes of a dependency
+// rendered for the terminal, or why there are none.
+func changelogPreview(v version) string {
+	notes, err := changelog(v)
+	if err != nil {
+		return err.Error()
+	}
+
+	lines := strings.Split(renderMarkdown(notes), "\n")
+	if len(lines) <= previewLines {
+		return strings.Join(lines, "\n")
+	}
+
+	more := fmt.Sprintf("... %d more lines, see gcu changelog %s", len(lines)-previewLines, v.path)
+	return strings.Join(append(lines[:previewLines], more), "\n")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangelogSections(t *testing.T) {
+	keepAChangelog := `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+## [Unreleased]
+- wip
+
+## [1.3.0] - 2022-03-01
+### Added
+- c
+
+## [1.2.0] - 2022-02-01
+### Fixed
+- b
+
+## [1.1.0] - 2022-01-01
+- a
+`
+	setext := "v2.1.0\n======\n\n* two\n\nv2.0.0\n======\n\n* one\n\n1.9.0\n=====\n\n* old\n"
+	fenced := "## v0.3.0\n\n```\n## v0.2.0\n```\n\n## v0.2.0\n\n#12 fixed\n\n## v0.1.0\n"
+
+	tests := []struct {
+		name     string
+		text     string
+		from, to string
+		want     string
+	}{
+		{"keep a changelog", keepAChangelog, "v1.1.0", "v1.3.0", "## [1.3.0] - 2022-03-01\n### Added\n- c\n\n## [1.2.0] - 2022-02-01\n### Fixed\n- b"},
+		{"target excluded above", keepAChangelog, "v1.1.0", "v1.2.0", "## [1.2.0] - 2022-02-01\n### Fixed\n- b"},
+		{"setext", setext, "v1.9.0", "v2.1.0", "v2.1.0\n======\n\n* two\n\nv2.0.0\n======\n\n* one"},
+		{"fenced and issue refs", fenced, "v0.1.0", "v0.3.0", "## v0.3.0\n\n```\n## v0.2.0\n```\n\n## v0.2.0\n\n#12 fixed"},
+		{"none in range", keepAChangelog, "v1.3.0", "v1.3.1", ""},
+		{"no versions", "# Changelog\n\nnothing\n", "v1.0.0", "v2.0.0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, changelogSections(tt.text, tt.from, tt.to))
+		})
+	}
+}
+
+func TestChangelogFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, "", changelogFile(dir))
+
+	for _, name := range []string{"README.md", "changes.txt", "CHANGELOG.md"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	assert.Equal(t, filepath.Join(dir, "CHANGELOG.md"), changelogFile(dir))
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	notes := "## [1.2.0](https://example.com/compare)\n\n" +
+		"### Fixed\n\n" +
+		"- a **bold** fix, see [#12](https://example.com/12)\n" +
+		"  * nested item\n\n" +
+		"Setext\n" +
+		"------\n\n" +
+		"```go\n" +
+		"foo.Bar()\n" +
+		"```"
+
+	assert.Equal(t, "1.2.0\n\n"+
+		"Fixed\n\n"+
+		"• a bold fix, see #12\n"+
+		"  • nested item\n\n"+
+		"Setext\n\n"+
+		"    foo.Bar()", renderMarkdown(notes))
+}
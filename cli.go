@@ -10,7 +10,14 @@ import (
 const gcuVersion = "0.1.1-dev"
 
 func main() {
-	app := &cli.App{
+	if err := newApp().Run(os.Args); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// newApp returns the gcu command line application.
+func newApp() *cli.App {
+	return &cli.App{
 		Name:  "gcu (go-check-updates)",
 		Usage: "check for updates in go.mod dependency and go's binary files",
 		Flags: []cli.Flag{
@@ -38,6 +45,21 @@ func main() {
 				Usage:   "Rewrite all dependencies to latest version in your project",
 				Value:   true,
 			},
+			&cli.BoolFlag{
+				Name:  "rewrite-text",
+				Usage: "Also rewrite module paths in comments and directives of go files and in the --text-files",
+				Value: false,
+			},
+			&cli.StringSliceFlag{
+				Name:  "text-files",
+				Usage: "Globs of the text files checked by --rewrite-text",
+				Value: cli.NewStringSlice(defaultTextFiles...),
+			},
+			&cli.BoolFlag{
+				Name:  "rename-pkg",
+				Usage: "Rename the references to a package whose name changes with the major version, instead of adding an import alias",
+				Value: false,
+			},
 			&cli.BoolFlag{
 				Name:  "safe",
 				Usage: "Only minor and patch releases are checked and updated",
@@ -54,6 +76,51 @@ func main() {
 				Usage:   "Tidy up your go.mod working file",
 				Value:   true,
 			},
+			&cli.BoolFlag{
+				Name:    "update-deps",
+				Aliases: []string{"u"},
+				Usage:   "Also upgrade the dependencies of the selected modules (like go get -u)",
+				Value:   false,
+			},
+			&cli.StringFlag{
+				Name:  "verify",
+				Usage: "Run `build,vet,test` after upgrading and drop the upgrades that break them",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Print the changes to go.mod and the imports without writing anything",
+				Value:   false,
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format of --dry-run, diff or patch (for git apply)",
+				Value: "diff",
+			},
+			&cli.BoolFlag{
+				Name:  "check-license",
+				Usage: "Warn about dependencies changing their license and confirm the ones outside of the allow-list, it downloads both versions of each module",
+				Value: true,
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Load the configuration from `FILE` instead of gcu/config.json in the user config directory",
+			},
+			&cli.StringFlag{
+				Name:    "vulndb",
+				Usage:   "Vulnerability database in OSV format, a directory, a file:// or an http(s) URL, empty to skip the check",
+				EnvVars: []string{"GOVULNDB"},
+				Value:   defaultVulnDB,
+			},
+			&cli.BoolFlag{
+				Name:  "security-only",
+				Usage: "Only propose the lowest versions fixing the known vulnerabilities of your dependencies",
+				Value: false,
+			},
+			&cli.StringFlag{
+				Name:  "max-go",
+				Usage: "Pick the highest versions whose go directive is not newer than `VERSION`, like 1.21",
+			},
 			&cli.BoolFlag{
 				Name:    "binary",
 				Aliases: []string{"b"},
@@ -66,6 +133,25 @@ func main() {
 				Usage:   "Check for binaries updates in your global directory",
 				Value:   false,
 			},
+			&cli.BoolFlag{
+				Name:  "path-scan",
+				Usage: "Check the go binaries of every directory of PATH, and the ones included in the config",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "reset-flags",
+				Usage: "Reinstall the binaries with a bare go install instead of their original -tags, -ldflags, -trimpath and CGO_ENABLED",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:  "rebuild-devel",
+				Usage: "Rebuild the binaries built from a local checkout at their recorded VCS revision",
+				Value: false,
+			},
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Only check the binaries whose name or module matches `REGEXP`",
+			},
 			&cli.BoolFlag{
 				Name:    "version",
 				Aliases: []string{"v"},
@@ -77,6 +163,71 @@ func main() {
 				Name:   "list",
 				Usage:  "List all direct dependencies available for update",
 				Action: listCmd,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "detail",
+						Usage: "Show the API changes between the current and the latest version",
+						Value: false,
+					},
+					&cli.BoolFlag{
+						Name:    "binary",
+						Aliases: []string{"b"},
+						Usage:   "List the outdated binaries instead of the dependencies",
+						Value:   false,
+					},
+					&cli.BoolFlag{
+						Name:    "global",
+						Aliases: []string{"g"},
+						Usage:   "List the outdated binaries of your global directory",
+						Value:   false,
+					},
+					&cli.BoolFlag{
+						Name:  "path-scan",
+						Usage: "List the outdated go binaries of every directory of PATH",
+						Value: false,
+					},
+					&cli.StringFlag{
+						Name:  "filter",
+						Usage: "Only list the binaries whose name or module matches `REGEXP`",
+					},
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "Report the API changes of a dependency and whether your code uses them",
+				ArgsUsage: "<module>[@version] [path]",
+				Action:    diffCmd,
+			},
+			{
+				Name:      "go",
+				Usage:     "Check for go releases and upgrade the go and toolchain directives",
+				ArgsUsage: "[path]",
+				Action:    goCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "index",
+						Usage: "Go release index, a JSON file, a file:// or an http(s) URL like the download page",
+						Value: defaultGoIndex,
+					},
+				},
+			},
+			{
+				Name:      "changelog",
+				Usage:     "Show the release notes between the current and the latest version of a dependency",
+				ArgsUsage: "<module>[@version] [path]",
+				Action:    changelogCmd,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "markdown",
+						Usage: "Print the release notes as Markdown",
+						Value: false,
+					},
+				},
+			},
+			{
+				Name:   "undo",
+				Usage:  "Revert the last successful upgrade",
+				Action: undoCmd,
 			},
 			{
 				Name:    "version",
@@ -87,8 +238,4 @@ func main() {
 		},
 		Action: gcuCmd,
 	}
-
-	if err := app.Run(os.Args); err != nil {
-		log.Fatalln(err)
-	}
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+// optionsOf parses the command line like gcu does and returns the upgrade options.
+func optionsOf(t *testing.T, args ...string) upgradeOptions {
+	var opts upgradeOptions
+
+	app := newApp()
+	app.Action = func(ctx *cli.Context) error {
+		opts = upgradeOptionsOf(ctx)
+		return nil
+	}
+	assert.Nil(t, app.Run(append([]string{"gcu"}, args...)))
+
+	return opts
+}
+
+func TestUpgradeOptionsOf(t *testing.T) {
+	opts := optionsOf(t)
+	assert.False(t, opts.text)
+	assert.Equal(t, defaultTextFiles, opts.textFiles)
+	assert.False(t, opts.renamePkg)
+
+	opts = optionsOf(t, "--rewrite-text", "--text-files", "*.proto", "--text-files", "deploy/*.yaml")
+	assert.True(t, opts.text)
+	assert.Equal(t, []string{"*.proto", "deploy/*.yaml"}, opts.textFiles)
+
+	opts = optionsOf(t, "--rename-pkg")
+	assert.True(t, opts.renamePkg)
+}
+
+func TestCheckLicenseDefault(t *testing.T) {
+	check := func(args ...string) bool {
+		var on bool
+		app := newApp()
+		app.Action = func(ctx *cli.Context) error {
+			on = ctx.Bool("check-license")
+			return nil
+		}
+		assert.Nil(t, app.Run(append([]string{"gcu"}, args...)))
+		return on
+	}
+
+	assert.True(t, check())
+	assert.False(t, check("--check-license=false"))
+}
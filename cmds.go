@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
 	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/mod/semver"
 )
 
 func gcuCmd(ctx *cli.Context) error {
@@ -24,15 +30,7 @@ func gcuCmd(ctx *cli.Context) error {
 	}
 
 	if ctx.Bool("binary") {
-		if ctx.Bool("global") {
-			filePath = filepath.Join(os.Getenv("GOPATH"), "bin")
-		}
-
-		if err := checkBinaries(filePath); err != nil {
-			return err
-		}
-
-		return nil
+		return binaryCmd(ctx)
 	}
 
 	versions, err := getVersions(*ctx, filePath)
@@ -45,27 +43,42 @@ func gcuCmd(ctx *cli.Context) error {
 		return nil
 	}
 
-	if ctx.Bool("all") {
-		s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
-		s.Prefix = "Updating... Please wait. "
-		if err := s.Color("cyan"); err != nil {
+	if err := markVulns(ctx, versions); err != nil {
+		if ctx.Bool("security-only") {
 			return err
 		}
+		printVulnDBError(err)
+	}
 
-		s.Start()
-
-		for _, v := range versions {
-			if err := upgrade(v.path, v.new, filePath, ctx.Bool("rewrite") && !ctx.Bool("safe"), ctx.Bool("tidy")); err != nil {
-				return err
-			}
+	if ctx.Bool("security-only") {
+		versions = securityUpdates(versions)
+		if len(versions) == 0 {
+			printNoVulns()
+			return nil
 		}
+	}
 
-		s.Stop()
-		printAllDepLatest()
+	if versions, err = checkGo(ctx, filePath, versions); err != nil {
+		return err
+	}
 
+	if len(versions) == 0 {
+		printNoCompatibleVersion(ctx.String("max-go"))
 		return nil
 	}
 
+	// vulnerable modules first, in each group.
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].tool != versions[j].tool {
+			return versions[j].tool
+		}
+		return versions[i].vuln != nil && versions[j].vuln == nil
+	})
+
+	if ctx.Bool("all") {
+		return finishUpgrade(ctx, filePath, versions, printAllDepLatest)
+	}
+
 	options := make([]string, 0, len(versions))
 	m1, m2, m3 := caculateMaxLenForEachItem(versions)
 
@@ -75,12 +88,15 @@ func gcuCmd(ctx *cli.Context) error {
 
 	idxs := make([]int, 0, len(options))
 
-	// disable MultiSelect answer output.
-	prompt := &MultiSelect{
-		survey.MultiSelect{
+	prompt := &detailSelect{
+		MultiSelect: survey.MultiSelect{
 			Message:  "Select the dependencies you need to upgrade: ",
 			Options:  options,
 			PageSize: ctx.Int("size"),
+			Help:     "the release notes of the focused dependency",
+		},
+		Details: func(i int) string {
+			return changelogPreview(versions[i])
 		},
 	}
 	err = survey.AskOne(prompt, &idxs)
@@ -91,27 +107,260 @@ func gcuCmd(ctx *cli.Context) error {
 		return err
 	}
 
+	selected := make([]version, 0, len(idxs))
+	for _, idx := range idxs {
+		selected = append(selected, versions[idx])
+	}
+
+	return finishUpgrade(ctx, filePath, selected, printPartDepLatest)
+}
+
+// finishUpgrade applies the chosen versions, or only prints them with --dry-run.
+func finishUpgrade(ctx *cli.Context, filePath string, versions []version, done func()) error {
+	versions, err := confirmLicenses(ctx, versions)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		printBye()
+		return nil
+	}
+
+	if ctx.Bool("dry-run") {
+		return dryRun(os.Stdout, filePath, versions, upgradeOptionsOf(ctx), ctx.String("format"))
+	}
+
+	res, err := upgradeWithSpinner(ctx, filePath, versions)
+	if err != nil {
+		return err
+	}
+
+	printRewriteStats(os.Stdout, res.rewrite)
+	printReplacements(filePath, res.replacements)
+	printVendorDelta(res.vendor)
+
+	if len(res.culprits) > 0 {
+		printCulprits(res)
+		return nil
+	}
+
+	done()
+
+	return nil
+}
+
+// markVulns looks up the current versions in the vulnerability database, with
+// --security-only the target becomes the lowest version fixing them.
+func markVulns(ctx *cli.Context, versions []version) error {
+	src := ctx.String("vulndb")
+	if src == "" {
+		return nil
+	}
+
 	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
-	s.Prefix = "Updating... Please wait. "
+	s.Prefix = "Checking vulnerabilities... Please wait. "
 	if err := s.Color("cyan"); err != nil {
 		return err
 	}
 
 	s.Start()
+	defer s.Stop()
 
-	for _, idx := range idxs {
-		if err := upgrade(versions[idx].path, versions[idx].new, filePath, ctx.Bool("rewrite") && !ctx.Bool("safe"), ctx.Bool("tidy")); err != nil {
+	db, err := openVulnDB(src)
+	if err != nil {
+		return err
+	}
+
+	for i := range versions {
+		v := &versions[i]
+		if len(db.modules[v.mod]) == 0 {
+			continue
+		}
+
+		mod, ok, err := query(v.mod, ctx.Bool("cached"))
+		if err != nil {
+			return err
+		}
+
+		candidates := []string{}
+		if ok {
+			candidates = mod.Versions
+		}
+
+		if v.vuln, err = db.report(*v, candidates, ctx.Bool("stable")); err != nil || v.vuln == nil {
+			continue
+		}
+
+		if ctx.Bool("security-only") && v.vuln.fix != "" {
+			v.new = v.vuln.fix
+		}
+
+		remaining, err := db.vulns(v.newPath(), v.new)
+		if err != nil {
 			return err
 		}
+		v.vuln.remaining = entryIDs(remaining)
 	}
 
+	return nil
+}
+
+// checkGo compares the go lines of the new versions to --max-go, or to the go line of our module.
+func checkGo(ctx *cli.Context, filePath string, versions []version) ([]version, error) {
+	limit, maxGo := ctx.String("max-go"), ctx.String("max-go") != ""
+	if maxGo && !semver.IsValid(goSemver(limit)) {
+		return nil, fmt.Errorf("invalid go version for --max-go: %s", limit)
+	}
+
+	if !maxGo {
+		name, err := findModFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		ours, err := ourDirectives(name)
+		if err != nil {
+			return nil, err
+		}
+		limit = ours.goVersion
+	}
+
+	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
+	s.Prefix = "Checking go versions... Please wait. "
+	if err := s.Color("cyan"); err != nil {
+		return nil, err
+	}
+
+	s.Start()
+	kept, blocked, err := checkGoVersions(versions, limit, maxGo, ctx.Bool("security-only"), ctx.Bool("cached"), ctx.Bool("stable"))
 	s.Stop()
-	printPartDepLatest()
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	for _, v := range blocked {
+		printBlockedFix(v, limit)
+	}
+
+	return kept, nil
+}
+
+// securityUpdates keeps the vulnerable dependencies with a fix.
+func securityUpdates(versions []version) []version {
+	fixes := make([]version, 0)
+	for _, v := range versions {
+		if v.vuln != nil && v.vuln.fix != "" {
+			fixes = append(fixes, v)
+		}
+	}
+
+	return fixes
+}
+
+// confirmLicenses warns about the dependencies changing their license and asks
+// whether to upgrade the ones moving to a license outside of the allow-list.
+// the declined ones are dropped.
+func confirmLicenses(ctx *cli.Context, versions []version) ([]version, error) {
+	if !ctx.Bool("check-license") {
+		return versions, nil
+	}
+
+	cfg, err := loadConfig(ctx.String("config"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
+	s.Prefix = "Checking licenses... Please wait. "
+	if err := s.Color("cyan"); err != nil {
+		return nil, err
+	}
+
+	s.Start()
+	changes := checkLicenses(versions)
+	s.Stop()
+
+	kept := make([]version, 0, len(versions))
+	for i, c := range changes {
+		if !c.changed {
+			kept = append(kept, versions[i])
+			continue
+		}
+
+		allowed := c.allowed(cfg)
+		printLicenseChange(c, allowed)
+		if allowed || ctx.Bool("dry-run") {
+			kept = append(kept, versions[i])
+			continue
+		}
+
+		message := fmt.Sprintf("%s is not in your allowed licenses, upgrade %s anyway?", c.new, c.version.path)
+		if c.err != nil {
+			message = fmt.Sprintf("the license of %s is unknown, upgrade it anyway?", c.version.path)
+		}
+
+		ok := false
+		prompt := &survey.Confirm{Message: message}
+		err := survey.AskOne(prompt, &ok)
+		if err == terminal.InterruptErr {
+			printBye()
+			os.Exit(0)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			kept = append(kept, versions[i])
+		}
+	}
+
+	return kept, nil
+}
+
+func upgradeOptionsOf(ctx *cli.Context) upgradeOptions {
+	return upgradeOptions{
+		rewrite: ctx.Bool("rewrite") && !ctx.Bool("safe"),
+		tidy:    ctx.Bool("tidy"),
+		update:  ctx.Bool("update-deps"),
+
+		text:      ctx.Bool("rewrite-text"),
+		textFiles: ctx.StringSlice("text-files"),
+		renamePkg: ctx.Bool("rename-pkg"),
+	}
+}
+
+// upgradeWithSpinner upgrades the given versions while showing a spinner.
+func upgradeWithSpinner(ctx *cli.Context, filePath string, versions []version) (*result, error) {
+	if len(versions) == 0 {
+		return &result{}, nil
+	}
+
+	checks, err := parseChecks(ctx.String("verify"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
+	s.Prefix = "Updating... Please wait. "
+	if err := s.Color("cyan"); err != nil {
+		return nil, err
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	opts := upgradeOptionsOf(ctx)
+	opts.verify = checks
+
+	return upgrade(filePath, versions, opts)
 }
 
 func listCmd(ctx *cli.Context) error {
+	if ctx.Bool("binary") {
+		return listBinaries(ctx)
+	}
+
 	filePath := ctx.Args().First()
 	if filePath == "" {
 		filePath = "."
@@ -127,15 +376,527 @@ func listCmd(ctx *cli.Context) error {
 		return nil
 	}
 
+	if versions, err = checkGo(ctx, filePath, versions); err != nil {
+		return err
+	}
+
+	root := ""
+	if ctx.Bool("detail") {
+		name, err := findModFile(filePath)
+		if err != nil {
+			return err
+		}
+		root = filepath.Dir(name)
+	}
+
+	header := table.Row{"lib", "current version", "latest version", "go"}
+	if ctx.Bool("detail") {
+		header = append(header, "api changes")
+	}
+
+	vulnErr := markVulns(ctx, versions)
+	if vulnErr == nil && ctx.String("vulndb") != "" {
+		header = append(header, "vulnerabilities")
+	}
+
+	var summaries []string
+	if ctx.Bool("detail") {
+		summaries = apiSummaries(root, versions)
+	}
+
+	var licenses []*licenseChange
+	var cfg *config
+	if ctx.Bool("check-license") {
+		if cfg, err = loadConfig(ctx.String("config")); err != nil {
+			return err
+		}
+
+		licenses = checkLicenses(versions)
+		header = append(header, "license")
+	}
+
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"lib", "current version", "latest version"})
-	for _, v := range versions {
-		t.AppendRow(table.Row{v.path, v.oldversion(), v.newVersion()})
+	t.AppendHeader(header)
+	for i, v := range versions {
+		lib := v.path
+		if v.tool {
+			if i > 0 && !versions[i-1].tool {
+				t.AppendSeparator()
+			}
+			lib += " (tool)"
+		}
+
+		row := table.Row{lib, v.oldversion(), v.newVersion(), goColumn(v.goMod)}
+		if summaries != nil {
+			row = append(row, summaries[i])
+		}
+		if vulnErr == nil && ctx.String("vulndb") != "" {
+			row = append(row, vulnColumn(v.vuln))
+		}
+		if licenses != nil {
+			row = append(row, licenseColumn(licenses[i], cfg))
+		}
+		t.AppendRow(row)
 	}
 
 	t.Render()
 
+	if vulnErr != nil {
+		printVulnDBError(vulnErr)
+	}
+
+	return nil
+}
+
+// checkableBinaries reads the binaries matching --filter and returns the outdated ones,
+// the devel ones to rebuild with --rebuild-devel and the ones go install can't upgrade.
+// they are grouped by directory.
+func checkableBinaries(ctx *cli.Context) (outdated, rebuilds, skipped []binary, err error) {
+	bins, err := scanBinaries(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if bins, err = filterBinaries(bins, ctx.String("filter")); err != nil {
+		return nil, nil, nil, err
+	}
+
+	order := dirOrder(bins)
+	bins, rebuilds, skipped = splitBinaries(bins, ctx.Bool("rebuild-devel"))
+	outdated, lookups, err := outdatedBinaries(bins, ctx.Bool("cached"), ctx.Bool("stable"), ctx.Bool("safe"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, err := range lookups {
+		printLookupError(err)
+	}
+
+	groupByDir(outdated, order)
+	groupByDir(rebuilds, order)
+	groupByDir(skipped, order)
+
+	return outdated, rebuilds, skipped, nil
+}
+
+// scanBinaries reads the binaries of every directory of PATH with --path-scan,
+// or the ones at the path given.
+func scanBinaries(ctx *cli.Context) ([]binary, error) {
+	if !ctx.Bool("path-scan") {
+		return readBinaries(binaryPath(ctx))
+	}
+
+	cfg, err := loadConfig(ctx.String("config"))
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := pathDirs(os.Getenv("PATH"), cfg.PathScan.Include, cfg.PathScan.Exclude, systemDirs)
+	bins := scanDirs(dirs)
+	markReadOnly(bins)
+
+	return bins, nil
+}
+
+// binaryCmd upgrades the selected binaries with go install.
+func binaryCmd(ctx *cli.Context) error {
+	bins, rebuilds, skipped, err := checkableBinaries(ctx)
+	if err != nil {
+		return err
+	}
+
+	printSkippedBinaries(skipped)
+
+	if len(bins) == 0 && len(rebuilds) == 0 {
+		printAllLibLatest()
+		return nil
+	}
+
+	if !ctx.Bool("all") && len(bins) > 0 {
+		versions := make([]version, 0, len(bins))
+		for _, b := range bins {
+			versions = append(versions, b.version)
+		}
+
+		m0 := 0
+		for _, b := range bins {
+			m0 = max(m0, len(b.name))
+		}
+		m1, m2, m3 := caculateMaxLenForEachItem(versions)
+
+		options := make([]string, 0, len(bins))
+		for _, b := range bins {
+			option := fmt.Sprintf("%-*s %s", m0, b.name, b.String(m1, m2, m3))
+			if ctx.Bool("path-scan") {
+				option += color.HiBlackString(" in %s", b.dir)
+			}
+			if b.majorChanged() {
+				option += color.RedString(" ⚠ new major version at %s, the command line may change", b.newPath())
+			}
+			options = append(options, option)
+		}
+
+		idxs := make([]int, 0, len(options))
+		prompt := &detailSelect{
+			MultiSelect: survey.MultiSelect{
+				Message:  "Select the binaries you need to upgrade: ",
+				Options:  options,
+				PageSize: ctx.Int("size"),
+				Help:     "the release notes of the focused binary",
+			},
+			Details: func(i int) string {
+				return changelogPreview(bins[i].version)
+			},
+		}
+		err = survey.AskOne(prompt, &idxs)
+		if err == terminal.InterruptErr {
+			printBye()
+			os.Exit(0)
+		} else if err != nil {
+			return err
+		}
+
+		selected := make([]binary, 0, len(idxs))
+		for _, idx := range idxs {
+			selected = append(selected, bins[idx])
+		}
+		bins = selected
+	}
+
+	bins = append(bins, rebuilds...)
+	if len(bins) == 0 {
+		printBye()
+		return nil
+	}
+
+	if ctx.Bool("dry-run") {
+		for _, b := range bins {
+			fmt.Println(b.installCommand(ctx.Bool("reset-flags")))
+		}
+		return nil
+	}
+
+	results, err := installBinaries(bins, ctx.Bool("reset-flags"))
+	if err != nil {
+		return err
+	}
+
+	printInstallSummary(results)
+
+	for _, r := range results {
+		if r.err != nil {
+			return errInstallFailed
+		}
+	}
+
+	return nil
+}
+
+// listBinaries renders the outdated go binaries without installing them.
+func listBinaries(ctx *cli.Context) error {
+	bins, _, skipped, err := checkableBinaries(ctx)
+	if err != nil {
+		return err
+	}
+
+	printSkippedBinaries(skipped)
+
+	if len(bins) == 0 {
+		printAllLibLatest()
+		return nil
+	}
+
+	// a table for each directory.
+	for start := 0; start < len(bins); {
+		end := start + 1
+		for end < len(bins) && bins[end].dir == bins[start].dir {
+			end++
+		}
+
+		renderBinaries(bins[start:end], ctx.Bool("path-scan"))
+		start = end
+	}
+
+	return nil
+}
+
+func renderBinaries(bins []binary, titled bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	if titled {
+		t.SetTitle(bins[0].dir)
+	}
+	t.AppendHeader(table.Row{"binary", "module", "installed version", "latest version"})
+	for i, b := range bins {
+		mod := b.mod
+		if b.majorChanged() {
+			// the new major versions may change the command line.
+			if i > 0 && !bins[i-1].majorChanged() {
+				t.AppendSeparator()
+			}
+			mod += " -> " + b.newPath()
+		}
+
+		t.AppendRow(table.Row{b.name, mod, b.oldversion(), b.newVersion()})
+	}
+	t.Render()
+}
+
+func diffCmd(ctx *cli.Context) error {
+	arg := ctx.Args().First()
+	if arg == "" {
+		return fmt.Errorf("usage: gcu diff <module>[@version] [path]")
+	}
+
+	filePath := ctx.Args().Get(1)
+	if filePath == "" {
+		filePath = "."
+	}
+
+	name, err := findModFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	v, err := resolveVersion(ctx, filePath, arg)
+	if err != nil {
+		return err
+	}
+
+	changes, err := apiDiff(filepath.Dir(name), v)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		printNoBreakingChanges(v)
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetTitle("%s %s -> %s", v.path, v.oldversion(), v.newVersion())
+	t.AppendHeader(table.Row{"package", "identifier", "change", "impact", "current", "new"})
+	for _, c := range changes {
+		pkg := joinPath(v.path, v.new, c.pkg)
+		t.AppendRow(table.Row{pkg, c.name, c.kind(), c.impact(), c.old, c.new})
+	}
+
+	t.Render()
+
+	return nil
+}
+
+func changelogCmd(ctx *cli.Context) error {
+	arg := ctx.Args().First()
+	if arg == "" {
+		return fmt.Errorf("usage: gcu changelog <module>[@version] [path]")
+	}
+
+	filePath := ctx.Args().Get(1)
+	if filePath == "" {
+		filePath = "."
+	}
+
+	v, err := resolveVersion(ctx, filePath, arg)
+	if err != nil {
+		return err
+	}
+
+	notes, err := changelog(v)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("markdown") {
+		fmt.Printf("# %s %s -> %s\n\n%s\n", v.path, v.old, v.new, notes)
+		return nil
+	}
+
+	printChangelogTitle(v)
+	fmt.Println(renderMarkdown(notes))
+
+	return nil
+}
+
+func goCmd(ctx *cli.Context) error {
+	filePath := ctx.Args().First()
+	if filePath == "" {
+		filePath = "."
+	}
+
+	name, err := findModFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	ours, err := ourDirectives(name)
+	if err != nil {
+		return err
+	}
+
+	if ours.goVersion == "" {
+		return fmt.Errorf("no go directive in %s", name)
+	}
+
+	releases, err := goReleases(ctx.String("index"))
+	if err != nil {
+		return err
+	}
+
+	// the toolchain line, when newer, is the go version the module builds with.
+	current := ours.goVersion
+	if tc := strings.TrimPrefix(ours.toolchain, "go"); tc != "" && compareGo(tc, current) > 0 {
+		current = tc
+	}
+
+	candidates := latestPatches(goCandidates(releases, current, goMinor(ours.goVersion), ctx.Bool("safe"), ctx.Bool("stable")))
+	if len(candidates) == 0 {
+		printGoLatest(current)
+		return nil
+	}
+
+	latest := candidates[len(candidates)-1]
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"directive", "current", "latest"})
+	t.AppendRow(table.Row{"go", ours.goVersion, latest})
+	if ours.toolchain != "" {
+		t.AppendRow(table.Row{"toolchain", ours.toolchain, "go" + latest})
+	}
+	t.Render()
+
+	target := latest
+	if !ctx.Bool("all") {
+		options := make([]string, 0, len(candidates))
+		for i := len(candidates) - 1; i >= 0; i-- {
+			options = append(options, candidates[i])
+		}
+
+		prompt := &survey.Select{
+			Message:  "Select the go version to upgrade to: ",
+			Options:  options,
+			PageSize: ctx.Int("size"),
+		}
+		err := survey.AskOne(prompt, &target)
+		if err == terminal.InterruptErr {
+			printBye()
+			os.Exit(0)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	data, err := editGoDirectives(name, target)
+	if err != nil {
+		return err
+	}
+
+	old, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	// go lines before 1.21 only name the language version.
+	if bytes.Equal(old, data) {
+		printGoLatest(ours.goVersion)
+		return nil
+	}
+
+	if ctx.Bool("dry-run") {
+		printDiff(os.Stdout, unifiedDiff("a/go.mod", "b/go.mod", string(old), string(data)))
+		return nil
+	}
+
+	tx := newTxn(filepath.Dir(name))
+	defer tx.close()
+
+	if err := tx.write(name, data); err != nil {
+		return err
+	}
+
+	if err := tx.commit(); err != nil {
+		if rerr := tx.rollback(); rerr != nil {
+			return fmt.Errorf("%v, rollback failed: %v", err, rerr)
+		}
+		return err
+	}
+
+	printGoUpgraded(target)
+
+	return nil
+}
+
+// resolveVersion finds the requirement of the module in go.mod and the version
+// to compare it to, the latest one unless it is given as module@version.
+func resolveVersion(ctx *cli.Context, dir, arg string) (version, error) {
+	modp, target := arg, ""
+	if i := strings.Index(arg, "@"); i >= 0 {
+		modp, target = arg[:i], arg[i+1:]
+	}
+
+	deps, err := direct(dir)
+	if err != nil {
+		return version{}, err
+	}
+
+	for _, dep := range deps {
+		if dep.Path != modp && modPrefix(dep.Path) != modPrefix(modp) {
+			continue
+		}
+
+		v := version{path: modPrefix(dep.Path), mod: dep.Path, old: dep.Version, new: target}
+		if target != "" {
+			return v, nil
+		}
+
+		var mod *Module
+		if ctx.Bool("safe") {
+			var ok bool
+			if mod, ok, err = query(dep.Path, ctx.Bool("cached")); err == nil && !ok {
+				err = fmt.Errorf("module not found: %s", dep.Path)
+			}
+		} else {
+			mod, err = latest(dep.Path, ctx.Bool("cached"))
+		}
+		if err != nil {
+			return version{}, err
+		}
+
+		prefix := ""
+		if ctx.Bool("safe") {
+			prefix = semver.Major(dep.Version) + "."
+		}
+
+		if v.new = mod.maxVersion(prefix, ctx.Bool("stable")); v.new == "" {
+			return version{}, fmt.Errorf("no version found for %s", dep.Path)
+		}
+
+		return v, nil
+	}
+
+	return version{}, fmt.Errorf("%s is not a direct dependency", modp)
+}
+
+func undoCmd(ctx *cli.Context) error {
+	filePath := ctx.Args().First()
+	if filePath == "" {
+		filePath = "."
+	}
+
+	name, err := findModFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	rec, err := undo(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	printUndone(rec)
+
 	return nil
 }
 
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// defaultAllowedLicenses are the licenses a dependency can move to without confirmation.
+var defaultAllowedLicenses = []string{"MIT", "Apache-2.0", "BSD-2-Clause", "BSD-3-Clause", "ISC", "MPL-2.0"}
+
+// config is the gcu configuration, read from gcu/config.json in the user config directory.
+type config struct {
+	Licenses struct {
+		// Allow are the SPDX identifiers of the licenses a dependency can be
+		// relicensed to without confirmation.
+		Allow []string `json:"allow"`
+	} `json:"licenses"`
+	PathScan struct {
+		// Include are the directories --path-scan reads besides the ones of PATH,
+		// Exclude the ones it never reads, with their subdirectories.
+		Include []string `json:"include"`
+		Exclude []string `json:"exclude"`
+	} `json:"path_scan"`
+}
+
+// configPath returns the default location of the config file.
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "gcu", "config.json")
+}
+
+// loadConfig reads the config file at name, or the default one if name is empty.
+// a missing default config file is not an error.
+func loadConfig(name string) (*config, error) {
+	explicit := name != ""
+	if !explicit {
+		name = configPath()
+	}
+
+	cfg := &config{}
+	data, err := ioutil.ReadFile(name)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config %s: %v", name, err)
+		}
+	case explicit || !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if cfg.Licenses.Allow == nil {
+		cfg.Licenses.Allow = defaultAllowedLicenses
+	}
+
+	return cfg, nil
+}
+
+// allowLicense reports whether a dependency can be relicensed to id without confirmation.
+func (c *config) allowLicense(id string) bool {
+	for _, allowed := range c.Licenses.Allow {
+		if allowed == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// memWriter keeps rewritten files in memory instead of writing them.
+type memWriter struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemWriter() *memWriter {
+	return &memWriter{files: make(map[string][]byte)}
+}
+
+func (m *memWriter) write(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[name] = data
+	return nil
+}
+
+// dryRun prints the changes an upgrade would make to go.mod and to the imports,
+// without writing anything. with the patch format the output can be fed to git apply.
+// what the go command would resolve afterwards (go.sum, indirect requirements)
+// is not part of the output.
+func dryRun(w io.Writer, dir string, versions []version, opts upgradeOptions, format string) error {
+	if format != "diff" && format != "patch" {
+		return fmt.Errorf("unknown format %q, must be diff or patch", format)
+	}
+
+	// the rewritten files are compared to the repository root, which is absolute.
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	name, err := findModFile(dir)
+	if err != nil {
+		return err
+	}
+
+	data, err := editModFile(name, versions, opts.rewrite)
+	if err != nil {
+		return err
+	}
+
+	files := newMemWriter()
+	files.files[name] = data
+
+	p := newPlan(versions)
+	if opts.rewrite && len(p.moved) > 0 {
+		r := p.rewriter(files, opts)
+		if err := r.walk(dir); err != nil {
+			return err
+		}
+
+		// keep the output clean for git apply.
+		printRewriteStats(os.Stderr, &r.stats)
+	}
+
+	// paths in the patch are relative to the repository root, like git does.
+	base := filepath.Dir(name)
+	if git, err := findFile(base, ".git"); err == nil {
+		base = filepath.Dir(git)
+	}
+
+	names := make([]string, 0, len(files.files))
+	for name := range files.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		old, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		d := unifiedDiff("a/"+rel, "b/"+rel, string(old), string(files.files[name]))
+		if d == "" {
+			continue
+		}
+
+		if format == "patch" {
+			fmt.Fprintf(w, "diff --git a/%s b/%s\n%s", rel, rel, d)
+			continue
+		}
+
+		printDiff(w, d)
+	}
+
+	return nil
+}
+
+// printDiff prints a unified diff with colors.
+func printDiff(w io.Writer, d string) {
+	bold := color.New(color.Bold).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+
+	for _, line := range splitLines(d) {
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			line = bold(line)
+		case strings.HasPrefix(line, "@@"):
+			line = cyan(line)
+		case strings.HasPrefix(line, "-"):
+			line = red(line)
+		case strings.HasPrefix(line, "+"):
+			line = green(line)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/m\n\ngo 1.18\n\nrequire github.com/go-redis/redis/v8 v8.11.5\n",
+		"main.go": "package main\n\nimport \"github.com/go-redis/redis/v8\"\n\nvar _ = redis.Nil\n",
+	}
+	for name, data := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, name), []byte(data), 0644))
+	}
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(root))
+	defer func() {
+		assert.Nil(t, os.Chdir(wd))
+	}()
+
+	versions := []version{
+		{path: "github.com/go-redis/redis", mod: "github.com/go-redis/redis/v8", old: "v8.11.5", new: "v9.0.2"},
+	}
+
+	// the default path is relative to the working directory.
+	for _, dir := range []string{"", "."} {
+		var buf bytes.Buffer
+		assert.Nil(t, dryRun(&buf, dir, versions, upgradeOptions{rewrite: true}, "patch"))
+		assert.Equal(t, `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+@@ -2,4 +2,4 @@
+ 
+ go 1.18
+ 
+-require github.com/go-redis/redis/v8 v8.11.5
++require github.com/go-redis/redis/v9 v9.0.2
+diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ package main
+ 
+-import "github.com/go-redis/redis/v8"
++import "github.com/go-redis/redis/v9"
+ 
+ var _ = redis.Nil
+`, buf.String())
+	}
+}
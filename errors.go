@@ -2,4 +2,11 @@ package main
 
 import "errors"
 
-var errCanNotFindGoModFile = errors.New("can't find go.mod file in your designated path")
+var (
+	errCanNotFindGoModFile = errors.New("can't find go.mod file in your designated path")
+	errInterrupted         = errors.New("interrupted, all changes have been rolled back")
+	errNothingToUndo       = errors.New("nothing to undo for this module")
+	errNoChangelog         = errors.New("no release notes found in the module")
+	errInstallFailed       = errors.New("some binaries failed to install")
+	errStopWalk            = errors.New("stop walking")
+)
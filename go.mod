@@ -1,6 +1,6 @@
 module github.com/qianxi0410/gcu
 
-go 1.18
+go 1.22.0
 
 require (
 	github.com/AlecAivazis/survey/v2 v2.3.4
@@ -9,7 +9,7 @@ require (
 	github.com/jedib0t/go-pretty/v6 v6.3.1
 	github.com/stretchr/testify v1.7.1
 	github.com/urfave/cli/v2 v2.8.1
-	golang.org/x/mod v0.5.1
+	golang.org/x/mod v0.22.0
 )
 
 require (
@@ -32,6 +32,5 @@ require (
 	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
 	golang.org/x/term v0.0.0-20220526004731-065cf7ba2467 // indirect
 	golang.org/x/text v0.3.7 // indirect
-	golang.org/x/xerrors v0.0.0-20220517211312-f3a8303e98df // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
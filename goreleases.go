@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// defaultGoIndex lists every go release, like the download page of go.dev.
+const defaultGoIndex = "https://go.dev/dl/?mode=json&include=all"
+
+// goRelease is a release of the go download index.
+type goRelease struct {
+	// Version is like go1.21.3 or go1.22rc1.
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
+// goReleases reads the go download index at src, a local file, a file:// or an http(s) URL.
+func goReleases(src string) ([]goRelease, error) {
+	data, err := fetch(src)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]goRelease, 0)
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("go index %s: %v", src, err)
+	}
+
+	return releases, nil
+}
+
+// goMinor returns the language version of a go version, e.g. 1.21.3 => 1.21.
+func goMinor(v string) string {
+	v = strings.TrimPrefix(v, "go")
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return v
+	}
+
+	return parts[0] + "." + parts[1]
+}
+
+// goCandidates returns the releases newer than current, oldest first, without
+// the go prefix. with safe only the patch releases of the minor version are kept.
+func goCandidates(releases []goRelease, current, minor string, safe, stable bool) []string {
+	candidates := make([]string, 0)
+	for _, r := range releases {
+		v := strings.TrimPrefix(r.Version, "go")
+		if stable && !r.Stable || compareGo(v, current) <= 0 {
+			continue
+		}
+
+		if safe && goMinor(v) != minor {
+			continue
+		}
+
+		candidates = append(candidates, v)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return compareGo(candidates[i], candidates[j]) < 0
+	})
+
+	return candidates
+}
+
+// latestPatches keeps the newest release of each minor version of the sorted versions.
+func latestPatches(versions []string) []string {
+	latest := make([]string, 0)
+	for i, v := range versions {
+		if i+1 < len(versions) && goMinor(versions[i+1]) == goMinor(v) {
+			continue
+		}
+		latest = append(latest, v)
+	}
+
+	return latest
+}
+
+// editGoDirectives moves the go line of the go.mod file at name to the release.
+// go lines before 1.21 can't name a patch release, the toolchain line follows
+// the release when there is one and is dropped when the go line says the same.
+func editGoDirectives(name string, release string) ([]byte, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	line := release
+	if compareGo(goMinor(release), "1.21") < 0 {
+		line = goMinor(release)
+	}
+
+	if err := f.AddGoStmt(line); err != nil {
+		return nil, err
+	}
+
+	if f.Toolchain != nil {
+		if compareGo(release, line) <= 0 {
+			f.DropToolchainStmt()
+		} else if err := f.AddToolchainStmt("go" + release); err != nil {
+			return nil, err
+		}
+	}
+
+	f.Cleanup()
+
+	return f.Format()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const goIndex = `[
+	{"version": "go1.23rc1", "stable": false},
+	{"version": "go1.22.2", "stable": true},
+	{"version": "go1.22.1", "stable": true},
+	{"version": "go1.22.0", "stable": true},
+	{"version": "go1.21.9", "stable": true},
+	{"version": "go1.21.8", "stable": true},
+	{"version": "go1.21.0", "stable": true},
+	{"version": "go1.20.14", "stable": true}
+]`
+
+func TestGoCandidates(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.json")
+	assert.Nil(t, ioutil.WriteFile(index, []byte(goIndex), 0644))
+
+	for _, src := range []string{index, "file://" + filepath.ToSlash(index)} {
+		releases, err := goReleases(src)
+		assert.Nil(t, err)
+		assert.Equal(t, 8, len(releases))
+	}
+
+	releases, err := goReleases(index)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		current      string
+		safe, stable bool
+		want         []string
+	}{
+		{"all", "1.21.8", false, true, []string{"1.21.9", "1.22.0", "1.22.1", "1.22.2"}},
+		{"safe", "1.21.8", true, true, []string{"1.21.9"}},
+		{"language version", "1.21", true, true, []string{"1.21.0", "1.21.8", "1.21.9"}},
+		{"unstable", "1.22.2", false, false, []string{"1.23rc1"}},
+		{"latest", "1.22.2", false, true, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, goCandidates(releases, tt.current, goMinor(tt.current), tt.safe, tt.stable))
+		})
+	}
+
+	assert.Equal(t, []string{"1.21.9", "1.22.2"}, latestPatches([]string{"1.21.8", "1.21.9", "1.22.0", "1.22.2"}))
+}
+
+func TestEditGoDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		gomod   string
+		release string
+		want    string
+	}{
+		{"go line", "module m\n\ngo 1.21.0\n", "1.22.2", "module m\n\ngo 1.22.2\n"},
+		{"toolchain", "module m\n\ngo 1.21.0\n\ntoolchain go1.21.8\n", "1.22.2", "module m\n\ngo 1.22.2\n"},
+		{"language version", "module m\n\ngo 1.19\n", "1.20.14", "module m\n\ngo 1.20\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "go.mod")
+			assert.Nil(t, ioutil.WriteFile(name, []byte(tt.gomod), 0644))
+
+			data, err := editGoDirectives(name, tt.release)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// goDirectives are the go and toolchain lines of a go.mod file.
+type goDirectives struct {
+	goVersion string
+	// toolchain is only read from our go.mod, the go command ignores it in dependencies.
+	toolchain string
+	// newer reports whether the go line is newer than the go line of our module, or than --max-go.
+	newer bool
+}
+
+func (d *goDirectives) String() string {
+	if d.goVersion == "" {
+		return "none"
+	}
+
+	s := "go " + d.goVersion
+	if d.toolchain != "" {
+		s += ", toolchain " + d.toolchain
+	}
+
+	return s
+}
+
+// parseDirectives reads the go line of the go.mod file of a dependency.
+// the lax parser skips its toolchain line, which doesn't limit the go version
+// building our module, like the go command does.
+func parseDirectives(name string, data []byte) (*goDirectives, error) {
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &goDirectives{}
+	if f.Go != nil {
+		d.goVersion = f.Go.Version
+	}
+
+	return d, nil
+}
+
+// ourDirectives reads the go and toolchain lines of the go.mod file at name.
+func ourDirectives(name string) (*goDirectives, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &goDirectives{}
+	if f.Go != nil {
+		d.goVersion = f.Go.Version
+	}
+	if f.Toolchain != nil {
+		d.toolchain = f.Toolchain.Name
+	}
+
+	return d, nil
+}
+
+// goSemver turns a go version like 1.21, 1.21.3 or 1.21rc1 into a semantic version.
+// the language version 1.21 comes before its releases, like the go command orders them.
+func goSemver(v string) string {
+	v = strings.TrimPrefix(v, "go")
+
+	pre := ""
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		v, pre = v[:i], v[i:]
+	}
+
+	parts := strings.Split(v, ".")
+	switch {
+	case len(parts) == 2 && pre == "":
+		return "v" + v + ".0-0"
+	case len(parts) == 2:
+		return "v" + v + ".0-" + pre
+	case pre != "":
+		return "v" + v + "-" + pre
+	}
+
+	return "v" + v
+}
+
+// compareGo compares two go versions, the result is like semver.Compare.
+func compareGo(a, b string) int {
+	return semver.Compare(goSemver(a), goSemver(b))
+}
+
+// versionDirectives fetches the go line of a module version from the proxy and compares it to limit.
+func versionDirectives(modp, ver, limit string, cached bool) (*goDirectives, error) {
+	data, err := queryMod(modp, ver, cached)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := parseDirectives(modp+"@"+ver+"/go.mod", data)
+	if err != nil {
+		return nil, err
+	}
+
+	d.newer = d.goVersion != "" && limit != "" && compareGo(d.goVersion, limit) > 0
+
+	return d, nil
+}
+
+// modCacheDir returns the module cache directory, empty if the go command can't tell.
+func modCacheDir() string {
+	output, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// cachedDirectives reads the go line of a module version from the module cache at
+// cache and compares it to limit, nil if the version is not in the cache.
+func cachedDirectives(cache, modp, ver, limit string) *goDirectives {
+	escaped, err := module.EscapePath(modp)
+	if err != nil || cache == "" {
+		return nil
+	}
+
+	escapedVer, err := module.EscapeVersion(ver)
+	if err != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(cache, "cache", "download", escaped, "@v", escapedVer+".mod"))
+	if err != nil {
+		return nil
+	}
+
+	d, err := parseDirectives(modp+"@"+ver+"/go.mod", data)
+	if err != nil {
+		return nil
+	}
+
+	d.newer = d.goVersion != "" && limit != "" && compareGo(d.goVersion, limit) > 0
+
+	return d
+}
+
+// compatibleVersion returns the highest version of the module above old, and not below
+// floor if set, whose go line is not newer than limit, and its directives.
+func compatibleVersion(modp string, old, floor, limit string, cached, stable bool) (string, *goDirectives, error) {
+	mod, ok, err := query(modp, cached)
+	if err != nil || !ok {
+		return "", nil, err
+	}
+
+	versions := append([]string(nil), mod.Versions...)
+	semver.Sort(versions)
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if semver.Compare(v, old) <= 0 || floor != "" && semver.Compare(v, floor) < 0 {
+			break
+		}
+
+		if stable && semver.Prerelease(v) != "" {
+			continue
+		}
+
+		d, err := versionDirectives(modp, v, limit, cached)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if !d.newer {
+			return v, d, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// checkGoVersions looks the go lines of the new versions up and compares them to limit,
+// without maxGo they are only read from the module cache.
+// with maxGo, the versions needing a newer go are moved to the highest compatible
+// version, of the new module path first, and dropped if there is none.
+// with securityOnly, a vulnerable dependency is not moved below the version fixing it,
+// it is blocked when no fixing version is compatible.
+func checkGoVersions(versions []version, limit string, maxGo, securityOnly, cached, stable bool) (kept, blocked []version, err error) {
+	errs := make([]error, len(versions))
+	keep := make([]bool, len(versions))
+
+	// without --max-go the go line is only a hint, it is not worth a request per
+	// version, the go.mod files already in the module cache are read instead.
+	cache := ""
+	if !maxGo {
+		cache = modCacheDir()
+	}
+
+	parallel(len(versions), func(i int) {
+		v := &versions[i]
+
+		if !maxGo {
+			v.goMod = cachedDirectives(cache, v.newPath(), v.new, limit)
+			keep[i] = true
+			return
+		}
+
+		d, err := versionDirectives(v.newPath(), v.new, limit, cached)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+
+		v.goMod = d
+		if !d.newer {
+			keep[i] = true
+			return
+		}
+
+		paths := []string{v.newPath()}
+		if v.majorChanged() {
+			paths = append(paths, v.mod)
+		}
+
+		floor := ""
+		if securityOnly && v.vuln != nil {
+			floor = v.vuln.fix
+		}
+
+		for _, modp := range paths {
+			old := v.old
+			if modp != v.mod {
+				old = ""
+			}
+
+			ver, d, err := compatibleVersion(modp, old, floor, limit, cached, stable)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			if ver != "" {
+				v.new, v.goMod, keep[i] = ver, d, true
+				return
+			}
+		}
+	})
+
+	kept = make([]version, 0, len(versions))
+	for i, v := range versions {
+		if errs[i] != nil {
+			return nil, nil, fmt.Errorf("%s: %v", v.path, errs[i])
+		}
+
+		switch {
+		case keep[i]:
+			kept = append(kept, v)
+		case securityOnly && v.vuln != nil:
+			blocked = append(blocked, v)
+		}
+	}
+
+	return kept, blocked, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareGo(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.21", "1.21", 0},
+		{"1.21", "1.21.0", -1},
+		{"1.21rc1", "1.21.0", -1},
+		{"1.21", "1.21rc1", -1},
+		{"1.21.3", "1.22", -1},
+		{"go1.23.1", "1.23.0", 1},
+		{"1.9", "1.18", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, compareGo(tt.a, tt.b))
+		})
+	}
+}
+
+func TestCheckGoVersions(t *testing.T) {
+	files := map[string]string{
+		"/example.com/foo/@v/list":          "v1.0.0\nv1.1.0\nv1.2.0\nv1.3.0\n",
+		"/example.com/foo/@v/v1.3.0.mod":    "module example.com/foo\n\ngo 1.23\n\ntoolchain go1.23.1\n",
+		"/example.com/foo/@v/v1.2.0.mod":    "module example.com/foo\n\ngo 1.22\n",
+		"/example.com/foo/@v/v1.1.0.mod":    "module example.com/foo\n\ngo 1.21\n",
+		"/example.com/bar/v2/@v/list":       "v2.0.0\n",
+		"/example.com/bar/v2/@v/v2.0.0.mod": "module example.com/bar/v2\n\ngo 1.22\n",
+		"/example.com/bar/@v/list":          "v1.0.0\nv1.1.0\n",
+		"/example.com/bar/@v/v1.1.0.mod":    "module example.com/bar\n",
+	}
+	requests := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.Error(w, "not found: "+r.URL.Path, http.StatusGone)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	t.Setenv("GOPROXY", srv.URL)
+
+	versions := func() []version {
+		return []version{
+			{path: "example.com/foo", mod: "example.com/foo", old: "v1.0.0", new: "v1.3.0"},
+			{path: "example.com/bar", mod: "example.com/bar", old: "v1.0.0", new: "v2.0.0"},
+		}
+	}
+
+	// without --max-go only the go.mod files of the module cache are read.
+	cache := t.TempDir()
+	t.Setenv("GOMODCACHE", cache)
+	name := filepath.Join(cache, "cache", "download", "example.com", "foo", "@v", "v1.3.0.mod")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+	assert.Nil(t, ioutil.WriteFile(name, []byte(files["/example.com/foo/@v/v1.3.0.mod"]), 0644))
+
+	got, _, err := checkGoVersions(versions(), "1.21", false, false, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(got))
+	// the toolchain line of a dependency doesn't matter.
+	assert.Equal(t, "go 1.23", got[0].goMod.String())
+	assert.True(t, got[0].goMod.newer)
+	assert.Nil(t, got[1].goMod)
+	assert.Zero(t, atomic.LoadInt32(&requests))
+
+	got, _, err = checkGoVersions(versions(), "1.22", true, false, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"v1.2.0", "v2.0.0"}, []string{got[0].new, got[1].new})
+
+	got, _, err = checkGoVersions(versions(), "1.21", true, false, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"v1.1.0", "v1.1.0"}, []string{got[0].new, got[1].new})
+	assert.Equal(t, "none", got[1].goMod.String())
+
+	got, _, err = checkGoVersions(versions(), "1.20", true, false, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, "example.com/bar", got[0].path)
+
+	// the lowest version fixing a vulnerability is the lowest one picked.
+	vulnerable := func(fix string) []version {
+		return []version{{path: "example.com/foo", mod: "example.com/foo", old: "v1.0.0", new: fix, vuln: &vulnReport{ids: []string{"GO-2022-0001"}, fix: fix}}}
+	}
+
+	got, blocked, err := checkGoVersions(vulnerable("v1.1.0"), "1.22", true, true, false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.1.0", got[0].new)
+	assert.Empty(t, blocked)
+
+	got, blocked, err = checkGoVersions(vulnerable("v1.3.0"), "1.22", true, true, false, true)
+	assert.Nil(t, err)
+	assert.Empty(t, got)
+	assert.Equal(t, "example.com/foo", blocked[0].path)
+}
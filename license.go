@@ -0,0 +1,269 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// unknownLicense is the identifier of the license texts the matcher doesn't recognize.
+const unknownLicense = "unknown"
+
+// licenseNames are the prefixes of the license file names, e.g. LICENSE, LICENSE-MIT or COPYING.md.
+var licenseNames = []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"}
+
+// licenseTitle is how far from the start of the normalized text the title of a license is looked for.
+const licenseTitle = 300
+
+// licenseRule recognizes a license by phrases found in its normalized text.
+type licenseRule struct {
+	id string
+	// title is looked for at the start of the text instead of the phrases,
+	// as the gnu and mozilla licenses mention each other.
+	title string
+	all   []string
+}
+
+// licenseRules are tried in order, the more specific ones first
+// (e.g. the commons clause is appended to permissive licenses).
+var licenseRules = []licenseRule{
+	{id: "Commons-Clause", all: []string{"commons clause"}},
+	{id: "SSPL-1.0", all: []string{"server side public license"}},
+	{id: "BUSL-1.1", all: []string{"business source license"}},
+	{id: "Elastic-2.0", all: []string{"elastic license 2.0"}},
+	{id: "AGPL-3.0", title: "gnu affero general public license version 3"},
+	{id: "LGPL-3.0", title: "gnu lesser general public license version 3"},
+	{id: "LGPL-2.1", title: "gnu lesser general public license version 2.1"},
+	{id: "LGPL-2.0", title: "gnu library general public license"},
+	{id: "GPL-3.0", title: "gnu general public license version 3"},
+	{id: "GPL-2.0", title: "gnu general public license version 2"},
+	{id: "MPL-2.0", title: "mozilla public license version 2.0"},
+	{id: "EPL-2.0", title: "eclipse public license v 2.0"},
+	{id: "Apache-2.0", all: []string{"apache license", "version 2.0"}},
+	{id: "BSL-1.0", all: []string{"boost software license"}},
+	{id: "Unlicense", all: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "CC0-1.0", all: []string{"cc0 1.0 universal"}},
+	{id: "BSD-3-Clause", all: []string{"redistribution and use in source and binary forms", "endorse or promote products derived from this software"}},
+	{id: "BSD-2-Clause", all: []string{"redistribution and use in source and binary forms"}},
+	{id: "MIT", all: []string{"permission is hereby granted free of charge to any person obtaining a copy"}},
+	{id: "ISC", all: []string{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted"}},
+	{id: "Zlib", all: []string{"altered source versions must be plainly marked as such"}},
+}
+
+// osiLicenses are the licenses of licenseRules approved by the open source initiative.
+var osiLicenses = map[string]bool{
+	"AGPL-3.0": true, "LGPL-3.0": true, "LGPL-2.1": true, "LGPL-2.0": true, "GPL-3.0": true, "GPL-2.0": true,
+	"MPL-2.0": true, "EPL-2.0": true, "Apache-2.0": true, "BSL-1.0": true, "Unlicense": true,
+	"BSD-3-Clause": true, "BSD-2-Clause": true, "MIT": true, "ISC": true, "Zlib": true,
+}
+
+var (
+	spdxRe       = regexp.MustCompile(`(?i)spdx-license-identifier:\s*([\w.+-]+)`)
+	nonAlphaNum  = regexp.MustCompile(`[^a-z0-9.]+`)
+	sentenceDots = regexp.MustCompile(`\.( |$)`)
+)
+
+// classifyLicense returns the SPDX identifier of a license text, or unknownLicense.
+// the license whose title comes first wins, the others are matched in order.
+func classifyLicense(text string) string {
+	if m := spdxRe.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+
+	norm := normalizeLicense(text)
+	start := norm
+	if len(start) > licenseTitle {
+		start = start[:licenseTitle]
+	}
+
+	id, first := "", len(start)
+	for _, r := range licenseRules {
+		if r.title == "" {
+			continue
+		}
+
+		if i := strings.Index(start, r.title); i >= 0 && i < first {
+			id, first = r.id, i
+		}
+	}
+
+	if id != "" {
+		return id
+	}
+
+	for _, r := range licenseRules {
+		if r.title == "" && r.match(norm) {
+			return r.id
+		}
+	}
+
+	return unknownLicense
+}
+
+func (r *licenseRule) match(norm string) bool {
+	for _, phrase := range r.all {
+		if !strings.Contains(norm, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeLicense lowercases the text and turns punctuation and spaces into single spaces,
+// so the phrases match whatever the line wrapping and the quoting.
+func normalizeLicense(text string) string {
+	norm := nonAlphaNum.ReplaceAllString(strings.ToLower(text), " ")
+	return strings.TrimSpace(sentenceDots.ReplaceAllString(norm, " "))
+}
+
+// moduleLicense returns the license of the module in dir, the identifiers of several
+// license files are joined with OR, and the normalized texts to tell unknown licenses apart.
+func moduleLicense(dir string) (id, text string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", ""
+	}
+
+	ids := make([]string, 0)
+	texts := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() || !isLicenseFile(e.Name()) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, classifyLicense(string(data)))
+		texts = append(texts, normalizeLicense(string(data)))
+	}
+
+	if len(ids) == 0 {
+		return "", ""
+	}
+
+	sort.Strings(ids)
+	sort.Strings(texts)
+
+	return strings.Join(dedup(ids), " OR "), strings.Join(texts, "\n")
+}
+
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range licenseNames {
+		if upper == prefix || strings.HasPrefix(upper, prefix+".") || strings.HasPrefix(upper, prefix+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dedup(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// licenseChange is the license of a dependency in the current and the target version.
+type licenseChange struct {
+	version version
+	old     string
+	new     string
+	// changed reports whether the license texts differ in a way that matters:
+	// another license, or another unknown text.
+	changed bool
+	// err is why the licenses can't be compared, the new license is then unknown.
+	err error
+}
+
+// osi reports whether the new license is approved by the open source initiative,
+// any of them for dual licenses.
+func (c *licenseChange) osi() bool {
+	for _, id := range strings.Split(c.new, " OR ") {
+		if osiLicenses[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed reports whether the new license is in the allow-list of the config,
+// any of them for dual licenses.
+func (c *licenseChange) allowed(cfg *config) bool {
+	if c.err != nil {
+		return false
+	}
+
+	for _, id := range strings.Split(c.new, " OR ") {
+		if cfg.allowLicense(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *licenseChange) String() string {
+	old, new := c.old, c.new
+	if old == "" {
+		old = "none"
+	}
+	if new == "" {
+		new = "none"
+	}
+
+	if !c.osi() {
+		return old + " -> " + new + " (not OSI approved)"
+	}
+
+	return old + " -> " + new
+}
+
+// checkLicense compares the license files in the module zips of the current and the target version.
+func checkLicense(v version) (*licenseChange, error) {
+	oldDir, err := downloadDir(v.mod, v.old)
+	if err != nil {
+		return nil, err
+	}
+
+	newDir, err := downloadDir(v.newPath(), v.new)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &licenseChange{version: v}
+	var oldText, newText string
+	c.old, oldText = moduleLicense(oldDir)
+	c.new, newText = moduleLicense(newDir)
+	c.changed = c.old != c.new || c.new == unknownLicense && oldText != newText
+
+	return c, nil
+}
+
+// checkLicenses checks the licenses of the versions, a few at a time, the results are
+// in the order of versions. a failed check is an unknown license that changes,
+// so it is not upgraded without asking.
+func checkLicenses(versions []version) []*licenseChange {
+	changes := make([]*licenseChange, len(versions))
+	parallel(len(versions), func(i int) {
+		c, err := checkLicense(versions[i])
+		if err != nil {
+			c = &licenseChange{version: versions[i], new: unknownLicense, changed: true, err: err}
+		}
+		changes[i] = c
+	})
+
+	return changes
+}
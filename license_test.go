@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyLicense(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"mit", "MIT License\n\nCopyright (c) 2022 x\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\nof this software", "MIT"},
+		{"apache", "                                 Apache License\n                           Version 2.0, January 2004\n", "Apache-2.0"},
+		{"bsd3", "Redistribution and use in source and binary forms, with or without\nmodification, are permitted ... Neither the name of Google Inc. nor the names of its\ncontributors may be used to endorse or promote products derived from\nthis software without specific prior written permission.", "BSD-3-Clause"},
+		{"bsd2", "Redistribution and use in source and binary forms, with or without modification, are permitted", "BSD-2-Clause"},
+		{"gpl3", "GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n... use the GNU Lesser General Public License instead", "GPL-3.0"},
+		{"lgpl3", "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n... version 3 of the GNU General Public License", "LGPL-3.0"},
+		{"mpl", "Mozilla Public License Version 2.0\n... the GNU Lesser General Public License, Version 2.1", "MPL-2.0"},
+		{"busl", "Business Source License 1.1\n\nParameters\n\nLicensor: x", "BUSL-1.1"},
+		{"commons clause", "\"Commons Clause\" License Condition v1.0\n\n Apache License Version 2.0", "Commons-Clause"},
+		{"spdx", "SPDX-License-Identifier: EUPL-1.2\n", "EUPL-1.2"},
+		{"unknown", "All rights reserved.", unknownLicense},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyLicense(tt.text))
+		})
+	}
+}
+
+func TestModuleLicense(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"LICENSE-MIT":    "Permission is hereby granted, free of charge, to any person obtaining a copy",
+		"LICENSE-APACHE": "Apache License\nVersion 2.0, January 2004",
+		"LICENSES.go":    "package licenses",
+	}
+	for name, content := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	id, _ := moduleLicense(dir)
+	assert.Equal(t, "Apache-2.0 OR MIT", id)
+
+	c := &licenseChange{old: "MIT", new: "BUSL-1.1", changed: true}
+	cfg := &config{}
+	cfg.Licenses.Allow = defaultAllowedLicenses
+	assert.False(t, c.allowed(cfg))
+	assert.Equal(t, "MIT -> BUSL-1.1 (not OSI approved)", c.String())
+
+	c.new = "BUSL-1.1 OR MIT"
+	assert.True(t, c.allowed(cfg))
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := loadConfig("")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultAllowedLicenses, cfg.Licenses.Allow)
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+
+	name := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, ioutil.WriteFile(name, []byte(`{"licenses": {"allow": ["MIT"]}, "path_scan": {"include": ["~/tools"], "exclude": ["/usr/bin"]}}`), 0644))
+
+	cfg, err = loadConfig(name)
+	assert.Nil(t, err)
+	assert.True(t, cfg.allowLicense("MIT"))
+	assert.False(t, cfg.allowLicense("Apache-2.0"))
+	assert.Equal(t, []string{"~/tools"}, cfg.PathScan.Include)
+	assert.Equal(t, []string{"/usr/bin"}, cfg.PathScan.Exclude)
+}
+
+func TestCheckLicensesError(t *testing.T) {
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	v := version{path: "example.com/foo", mod: "example.com/foo", old: "v1.0.0", new: "v1.1.0"}
+	changes := checkLicenses([]version{v})
+	assert.Len(t, changes, 1)
+
+	// a module which can't be downloaded has an unknown license, never allowed.
+	c := changes[0]
+	assert.NotNil(t, c.err)
+	assert.True(t, c.changed)
+	assert.Equal(t, unknownLicense, c.new)
+
+	cfg := &config{}
+	cfg.Licenses.Allow = []string{unknownLicense}
+	assert.False(t, c.allowed(cfg))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// systemDirs are the directories of PATH owned by the system or a package manager,
+// --path-scan only checks them when they are included in the config.
+// /usr/local/bin is where go binaries are often installed, it is checked unless
+// it isn't writable, see markReadOnly.
+var systemDirs = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/games", "/opt/homebrew/bin", "/snap/bin"}
+
+// pathDirs returns the directories of the PATH list followed by the included ones,
+// deduplicated by the directory they link to, without the excluded ones and their
+// subdirectories. the system directories of PATH are skipped too, unless included.
+// the empty entries, meaning the working directory, are skipped.
+func pathDirs(pathList string, include, exclude, system []string) []string {
+	userExcluded := resolveDirs(exclude)
+	excluded := append(resolveDirs(system), userExcluded...)
+
+	dirs := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(dir string, excluded []string) {
+		if dir == "" {
+			return
+		}
+
+		dir = filepath.Clean(expandHome(dir))
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil || seen[resolved] || underAny(resolved, excluded) || underAny(dir, excluded) {
+			return
+		}
+
+		seen[resolved] = true
+		dirs = append(dirs, dir)
+	}
+
+	for _, dir := range filepath.SplitList(pathList) {
+		add(dir, excluded)
+	}
+
+	// an included system directory is selected explicitly.
+	for _, dir := range include {
+		add(dir, userExcluded)
+	}
+
+	return dirs
+}
+
+// resolveDirs expands ~ and resolves the links of the directories.
+func resolveDirs(dirs []string) []string {
+	resolved := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		dir = expandHome(dir)
+		if r, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = r
+		}
+		resolved = append(resolved, filepath.Clean(dir))
+	}
+
+	return resolved
+}
+
+// writableDir reports whether files can be created in dir, where go install writes.
+func writableDir(dir string) bool {
+	f, err := ioutil.TempFile(dir, ".gcu-*")
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	os.Remove(f.Name())
+
+	return true
+}
+
+// markReadOnly skips the binaries in a directory the user can't write to,
+// instead of failing their go install one by one.
+func markReadOnly(bins []binary) {
+	writable := make(map[string]bool)
+	for i := range bins {
+		b := &bins[i]
+		if b.kind != kindInstallable && b.kind != kindDevel {
+			continue
+		}
+
+		dir := filepath.Dir(b.file)
+		ok, checked := writable[dir]
+		if !checked {
+			ok = writableDir(dir)
+			writable[dir] = ok
+		}
+
+		if !ok {
+			b.kind = kindReadOnly
+		}
+	}
+}
+
+func expandHome(dir string) string {
+	if dir != "~" && !strings.HasPrefix(dir, "~/") {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dir
+	}
+
+	return filepath.Join(home, dir[1:])
+}
+
+// underAny reports whether dir is one of the parents or a subdirectory of one of them.
+func underAny(dir string, parents []string) bool {
+	for _, p := range parents {
+		if dir == p || strings.HasPrefix(dir, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanDirs reads the go executables of each directory, not recursively. the file of a
+// binary is the one its link points to, a binary linked from several directories is
+// read once, in the first of them.
+func scanDirs(dirs []string) []binary {
+	bins := make([]binary, 0)
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			resolved, err := filepath.EvalSymlinks(filepath.Join(dir, e.Name()))
+			if err != nil || seen[resolved] {
+				continue
+			}
+
+			st, err := os.Stat(resolved)
+			if err != nil || !st.Mode().IsRegular() {
+				continue
+			}
+
+			if runtime.GOOS != "windows" && st.Mode()&0111 == 0 {
+				continue
+			}
+
+			info, err := buildinfo.ReadFile(resolved)
+			if err != nil {
+				continue
+			}
+
+			seen[resolved] = true
+			b := newBinary(resolved, info)
+			b.name, b.dir = e.Name(), dir
+			bins = append(bins, b)
+		}
+	}
+
+	return bins
+}
+
+// dirOrder numbers the directories of the binaries in the order they come.
+func dirOrder(bins []binary) map[string]int {
+	order := make(map[string]int)
+	for _, b := range bins {
+		if _, ok := order[b.dir]; !ok {
+			order[b.dir] = len(order)
+		}
+	}
+
+	return order
+}
+
+// groupByDir sorts the binaries by directory, keeping their order in each directory.
+func groupByDir(bins []binary, order map[string]int) {
+	sort.SliceStable(bins, func(i, j int) bool {
+		return order[bins[i].dir] < order[bins[j].dir]
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathDirs(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	t.Setenv("HOME", home)
+
+	for _, dir := range []string{"go/bin", "local/bin", "usr/bin", "usr/bin/sub", "home/tools"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755))
+	}
+	assert.Nil(t, os.Symlink(filepath.Join(root, "go", "bin"), filepath.Join(root, "gobin")))
+
+	join := func(dirs ...string) string {
+		for i, dir := range dirs {
+			if dir != "" {
+				dirs[i] = filepath.Join(root, filepath.FromSlash(dir))
+			}
+		}
+		return strings.Join(dirs, string(os.PathListSeparator))
+	}
+
+	pathList := join("go/bin", "", "gobin", "local/bin", "missing", "usr/bin/sub", "go/bin/", "usr/bin")
+	got := pathDirs(pathList, []string{"~/tools", filepath.Join(root, "local", "bin")}, []string{filepath.Join(root, "usr", "bin")}, nil)
+	assert.Equal(t, []string{
+		filepath.Join(root, "go", "bin"),
+		filepath.Join(root, "local", "bin"),
+		filepath.Join(home, "tools"),
+	}, got)
+
+	// the system directories are only checked when included.
+	system := []string{filepath.Join(root, "usr"), filepath.Join(root, "local", "bin")}
+	got = pathDirs(pathList, nil, nil, system)
+	assert.Equal(t, []string{filepath.Join(root, "go", "bin")}, got)
+
+	got = pathDirs(pathList, []string{filepath.Join(root, "usr", "bin")}, nil, system)
+	assert.Equal(t, []string{filepath.Join(root, "go", "bin"), filepath.Join(root, "usr", "bin")}, got)
+}
+
+func TestMarkReadOnly(t *testing.T) {
+	// root can write anywhere, but not in a directory which is gone.
+	writable, readOnly := t.TempDir(), filepath.Join(t.TempDir(), "gone")
+
+	bins := []binary{
+		{name: "a", file: filepath.Join(writable, "a"), kind: kindInstallable},
+		{name: "b", file: filepath.Join(readOnly, "b"), kind: kindInstallable},
+		{name: "c", file: filepath.Join(readOnly, "c"), kind: kindReplaced},
+	}
+	markReadOnly(bins)
+	assert.Equal(t, []binaryKind{kindInstallable, kindReadOnly, kindReplaced}, []binaryKind{bins[0].kind, bins[1].kind, bins[2].kind})
+
+	// the probe leaves nothing behind.
+	entries, err := os.ReadDir(writable)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestScanDirs(t *testing.T) {
+	root := t.TempDir()
+	gobin, local := filepath.Join(root, "gobin"), filepath.Join(root, "local")
+	assert.Nil(t, os.MkdirAll(gobin, 0755))
+	assert.Nil(t, os.MkdirAll(local, 0755))
+
+	if !buildHello(t, filepath.Join(gobin, "hello")) {
+		return
+	}
+
+	// linked from another directory of PATH, read once.
+	assert.Nil(t, os.Symlink(filepath.Join(gobin, "hello"), filepath.Join(local, "hi")))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(local, "script.sh"), []byte("#!/bin/sh\n"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(local, "sub"), 0755))
+	data, err := ioutil.ReadFile(filepath.Join(gobin, "hello"))
+	assert.Nil(t, err)
+	// not read, the directories of PATH are not walked.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(local, "sub", "nested"), data, 0755))
+
+	bins := scanDirs([]string{local, gobin})
+	if assert.Equal(t, 1, len(bins)) {
+		assert.Equal(t, "hi", bins[0].name)
+		assert.Equal(t, local, bins[0].dir)
+		assert.Equal(t, filepath.Join(gobin, "hello"), bins[0].file)
+	}
+}
+
+func TestGroupByDir(t *testing.T) {
+	bins := []binary{
+		{name: "a", dir: "/go/bin"},
+		{name: "b", dir: "/usr/local/bin"},
+		{name: "c", dir: "/go/bin"},
+		{name: "d", dir: "/home/bin"},
+	}
+	order := map[string]int{"/usr/local/bin": 0, "/go/bin": 1, "/home/bin": 2}
+
+	groupByDir(bins, order)
+	names := make([]string, 0, len(bins))
+	for _, b := range bins {
+		names = append(names, b.name)
+	}
+	assert.Equal(t, []string{"b", "a", "c", "d"}, names)
+	assert.Equal(t, order, dirOrder(bins))
+}
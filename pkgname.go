@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pkgNames finds the package names behind the import paths of moved modules,
+// by reading the package clauses of both versions from the module cache.
+type pkgNames struct {
+	moved []version
+	// rename the references to the package instead of adding an import alias.
+	rename bool
+
+	// download returns the directory of a module version in the module cache.
+	download func(modp, ver string) (string, error)
+
+	mu   sync.Mutex
+	dirs map[string]*cacheDir // module@version -> directory in the module cache.
+}
+
+// cacheDir is a module version downloaded once, whoever asks for it first.
+type cacheDir struct {
+	once sync.Once
+	dir  string
+}
+
+func newPkgNames(moved []version, rename bool) *pkgNames {
+	return &pkgNames{moved: moved, rename: rename, download: downloadDir, dirs: make(map[string]*cacheDir)}
+}
+
+// names returns the package names of the old and the new import path.
+func (n *pkgNames) names(oldPath, newPath string) (oldName, newName string) {
+	return n.name(oldPath, true), n.name(newPath, false)
+}
+
+func (n *pkgNames) name(path string, old bool) string {
+	for _, v := range n.moved {
+		modp, ver := v.newPath(), v.new
+		if old {
+			modp, ver = v.mod, v.old
+		}
+
+		if path != modp && !strings.HasPrefix(path, modp+"/") {
+			continue
+		}
+
+		if dir := n.dir(modp, ver); dir != "" {
+			pkgdir := strings.TrimPrefix(path[len(modp):], "/")
+			if name := pkgNameIn(filepath.Join(dir, filepath.FromSlash(pkgdir))); name != "" {
+				return name
+			}
+		}
+
+		break
+	}
+
+	return assumedName(path)
+}
+
+// dir returns where the module version is in the module cache, downloading it if needed.
+// only the files of the same module version wait for each other's download.
+func (n *pkgNames) dir(modp, ver string) string {
+	key := modp + "@" + ver
+
+	n.mu.Lock()
+	d, ok := n.dirs[key]
+	if !ok {
+		d = &cacheDir{}
+		n.dirs[key] = d
+	}
+	n.mu.Unlock()
+
+	d.once.Do(func() {
+		// the name is guessed from the path when the download fails.
+		d.dir, _ = n.download(modp, ver)
+	})
+
+	return d.dir
+}
+
+// downloadDir returns where the module version is in the module cache, downloading it if needed.
+func downloadDir(modp, ver string) (string, error) {
+	var info struct {
+		Dir   string
+		Error string
+	}
+
+	output, err := exec.Command("go", "mod", "download", "-json", modp+"@"+ver).Output()
+	if jerr := json.Unmarshal(output, &info); jerr != nil && err == nil {
+		err = jerr
+	}
+
+	if info.Error != "" {
+		return "", fmt.Errorf("download %s@%s: %s", modp, ver, info.Error)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("download %s@%s: %v", modp, ver, err)
+	}
+
+	return info.Dir, nil
+}
+
+// pkgNameIn returns the package name of the go files in dir, tests excluded.
+// main is only returned when it is the only name found.
+func pkgNameIn(dir string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	name := ""
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, e.Name()), nil, parser.PackageClauseOnly)
+		if err != nil || f.Name.Name == "documentation" {
+			continue
+		}
+
+		if f.Name.Name != "main" {
+			return f.Name.Name
+		}
+		name = f.Name.Name
+	}
+
+	return name
+}
+
+// assumedName guesses the package name from the import path like goimports does,
+// e.g. github.com/x/go-foo/v2 => foo, gopkg.in/yaml.v3 => yaml.
+func assumedName(path string) string {
+	base := path[strings.LastIndex(path, "/")+1:]
+	if isMajor(base) && strings.Contains(path, "/") {
+		rest := path[:strings.LastIndex(path, "/")]
+		base = rest[strings.LastIndex(rest, "/")+1:]
+	}
+
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r >= 0x80)
+	}); i >= 0 {
+		base = base[:i]
+	}
+
+	return base
+}
+
+// renameRefs renames the qualified identifiers pkg.X to the new package names
+// and returns the patched source. only the X of the selectors X.Sel which are not
+// declared in the file are renamed, not the field names of composite literals
+// or the labels sharing the old name.
+func renameRefs(name string, src []byte, renames map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	unresolved := make(map[*ast.Ident]bool, len(f.Unresolved))
+	for _, ident := range f.Unresolved {
+		unresolved[ident] = true
+	}
+
+	patches := make([]patch, 0)
+	ast.Inspect(f, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok || !unresolved[ident] {
+			return true
+		}
+
+		if newName, ok := renames[ident.Name]; ok {
+			start := fset.Position(ident.Pos()).Offset
+			patches = append(patches, patch{start: start, end: start + len(ident.Name), text: newName})
+		}
+
+		return true
+	})
+
+	if len(patches) == 0 {
+		return src, nil
+	}
+
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].start < patches[j].start
+	})
+
+	return applyPatches(src, patches), nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssumedName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"github.com/x/foo", "foo"},
+		{"github.com/x/foo/v2", "foo"},
+		{"github.com/x/go-foo", "foo"},
+		{"github.com/x/foo-bar", "foo"},
+		{"gopkg.in/yaml.v3", "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, assumedName(tt.path))
+		})
+	}
+}
+
+func TestRewriteSourcePkgName(t *testing.T) {
+	dir := t.TempDir()
+	mods := map[string]string{
+		"foo@v1.0.0/foo.go": "package foo\n",
+		"foo@v2.0.0/foo.go": "package foov2\n",
+		"foo@v2.0.0/doc.go": "package foov2 // import \"example.com/foo/v2\"\n",
+		"foo@v2.0.0/x/x.go": "package x\n",
+		"foo@v1.0.0/x/x.go": "package x\n",
+	}
+	for name, content := range mods {
+		name = filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+
+	p := newPlan([]version{{path: "example.com/foo", mod: "example.com/foo", old: "v1.0.0", new: "v2.0.0"}})
+	newNames := func(rename bool) *pkgNames {
+		n := newPkgNames(p.moved, rename)
+		n.download = func(modp, ver string) (string, error) {
+			switch modp + "@" + ver {
+			case "example.com/foo@v1.0.0":
+				return filepath.Join(dir, "foo@v1.0.0"), nil
+			case "example.com/foo/v2@v2.0.0":
+				return filepath.Join(dir, "foo@v2.0.0"), nil
+			}
+			return "", os.ErrNotExist
+		}
+		return n
+	}
+	replace := func(pos token.Position, path string) (string, error) {
+		return p.replace(pos, path)
+	}
+
+	src := "package p\n\nimport (\n\t\"example.com/foo\"\n\t\"example.com/foo/x\"\n)\n\nfunc F() { foo.F(x.X) }\n\nfunc G(foo int) int { return foo }\n\nvar _ = struct{ foo int }{foo: 1}\n"
+
+	got, ok, err := rewriteSource("p.go", []byte(src), replace, newNames(false))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "package p\n\nimport (\n\tfoo \"example.com/foo/v2\"\n\t\"example.com/foo/v2/x\"\n)\n\nfunc F() { foo.F(x.X) }\n\nfunc G(foo int) int { return foo }\n\nvar _ = struct{ foo int }{foo: 1}\n", string(got))
+
+	got, ok, err = rewriteSource("p.go", []byte(src), replace, newNames(true))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "package p\n\nimport (\n\t\"example.com/foo/v2\"\n\t\"example.com/foo/v2/x\"\n)\n\nfunc F() { foov2.F(x.X) }\n\nfunc G(foo int) int { return foo }\n\nvar _ = struct{ foo int }{foo: 1}\n", string(got))
+}
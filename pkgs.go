@@ -65,6 +65,11 @@ func splitPath(modprefix, pkgpath string) (modpath, pkgdir string, ok bool) {
 		return
 	}
 
+	// e.g. "github.com/x/tool" is not the prefix of "github.com/x/toolbox".
+	if rest := pkgpath[len(modprefix):]; rest != "" && rest[0] != '/' && rest[0] != '.' {
+		return
+	}
+
 	modpathLen := len(modprefix)
 	if strings.HasPrefix(pkgpath[modpathLen:], "/") {
 		modpathLen++
@@ -87,13 +92,23 @@ func splitPath(modprefix, pkgpath string) (modpath, pkgdir string, ok bool) {
 
 // findModFile recursively search the given path for a go.mod file.
 // only search up.
-func findModFile(dir string) (path string, err error) {
+func findModFile(dir string) (string, error) {
+	path, err := findFile(dir, "go.mod")
+	if os.IsNotExist(err) {
+		return "", errCanNotFindGoModFile
+	}
+
+	return path, err
+}
+
+// findFile search the given dir and its parents for a file with the given name.
+func findFile(dir, name string) (path string, err error) {
 	if dir, err = filepath.Abs(dir); err != nil {
 		return "", err
 	}
 
 	for {
-		path = filepath.Join(dir, "go.mod")
+		path = filepath.Join(dir, name)
 		if _, err = os.Stat(path); err == nil {
 			return
 		}
@@ -109,7 +124,7 @@ func findModFile(dir string) (path string, err error) {
 		dir = filepath.Dir(dir)
 	}
 
-	return "", errCanNotFindGoModFile
+	return "", os.ErrNotExist
 }
 
 // direct returns the direct module deps.
@@ -165,6 +165,13 @@ func TestSplitPath(t *testing.T) {
 			pkgdir:    "",
 			ok:        true,
 		},
+		{
+			modprefix: "github.com/google/go-cmp",
+			pkgpath:   "github.com/google/go-cmpx/cmp",
+			modpath:   "",
+			pkgdir:    "",
+			ok:        false,
+		},
 	}
 
 	for _, tt := range tests {
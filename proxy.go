@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
@@ -17,6 +20,10 @@ import (
 
 const limit = 100
 
+// httpClient gives up on a proxy or a vulnerability database which doesn't answer,
+// instead of hanging when offline.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
 type Module struct {
 	Path     string
 	Versions []string
@@ -81,21 +88,99 @@ func (m *Module) nextMajorPath() (string, bool) {
 	return m.versionPath(next), true
 }
 
-// MakeModule will fetch versions from the proxy and return a Module.
-func query(modp string, cached bool) (*Module, bool, error) {
-	escaped, err := module.EscapePath(modp)
-	if err != nil {
-		return nil, false, err
+// fetch reads a local file, a file:// or an http(s) URL.
+func fetch(loc string) ([]byte, error) {
+	u, err := url.Parse(loc)
+	if err != nil || len(u.Scheme) <= 1 {
+		// a local file, or a windows path like C:\index.json.
+		return ioutil.ReadFile(loc)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return ioutil.ReadFile(filepath.FromSlash(u.Path))
+	case "http", "https":
+		res, err := httpClient.Get(loc)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s", loc, res.Status)
+		}
+
+		return ioutil.ReadAll(res.Body)
 	}
 
+	return nil, fmt.Errorf("%s: unsupported scheme %s", loc, u.Scheme)
+}
+
+// proxySite returns the first proxy of GOPROXY.
+func proxySite() string {
 	// get goproxy env
 	proxy := os.Getenv("GOPROXY")
 	if proxy == "" {
 		proxy = "https://proxy.golang.org,direct"
 	}
 
-	site := strings.Split(proxy, ",")[0]
-	url := fmt.Sprintf("%s/%s/@v/list", site, escaped)
+	return strings.Split(proxy, ",")[0]
+}
+
+// queryMod fetches the go.mod file of a module version from the proxy.
+func queryMod(modp, ver string, cached bool) ([]byte, error) {
+	escaped, err := module.EscapePath(modp)
+	if err != nil {
+		return nil, err
+	}
+
+	escapedVer, err := module.EscapeVersion(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/%s/@v/%s.mod", proxySite(), escaped, escapedVer)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached {
+		req.Header.Set("Disable-Module-Fetch", "true")
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		msg := string(body)
+		if msg == "" {
+			msg = res.Status
+		}
+
+		return nil, fmt.Errorf("proxy: %s", msg)
+	}
+
+	return body, nil
+}
+
+// MakeModule will fetch versions from the proxy and return a Module.
+func query(modp string, cached bool) (*Module, bool, error) {
+	escaped, err := module.EscapePath(modp)
+	if err != nil {
+		return nil, false, err
+	}
+
+	url := fmt.Sprintf("%s/%s/@v/list", proxySite(), escaped)
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, false, err
@@ -105,7 +190,7 @@ func query(modp string, cached bool) (*Module, bool, error) {
 		req.Header.Set("Disable-Module-Fetch", "true")
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient.Do(req)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1,154 +1,471 @@
 package main
 
 import (
-	"fmt"
+	"bytes"
+	"go/ast"
 	"go/parser"
-	"go/printer"
+	"go/scanner"
 	"go/token"
 	"io/fs"
-	"log"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type replaceFunc func(pos token.Position, path string) (string, error)
 
-func rewrite(dir string, replace replaceFunc) error {
-	return filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+// fileWriter receives the content of rewritten files.
+type fileWriter interface {
+	write(name string, data []byte) error
+}
+
+// rewriter rewrites the import paths of the go files in a directory tree.
+type rewriter struct {
+	replace replaceFunc
+	w       fileWriter
+	// text also rewrites module paths outside of the imports, nil when disabled.
+	text *textReplacer
+	// names keeps the code compiling when a package name changes, nil when disabled.
+	names *pkgNames
+	// prefixes are looked up in the raw bytes of a file before parsing it,
+	// files without any of them are left alone. nil means every file is parsed.
+	prefixes [][]byte
+	// workers is the number of files handled at the same time, GOMAXPROCS if zero.
+	workers int
+
+	mu    sync.Mutex
+	stats rewriteStats
+}
+
+// rewriteStats sums up what a rewrite did.
+type rewriteStats struct {
+	// rewritten and unchanged count the go and text files looked at.
+	rewritten int
+	unchanged int
+	// skipped are the directories ignored like the go tool does.
+	skipped []string
+	// failed are the files which can't be parsed, they are left as they are.
+	failed []error
+}
+
+func rewrite(dir string, replace replaceFunc, w fileWriter) error {
+	return (&rewriter{replace: replace, w: w}).walk(dir)
+}
+
+// skipDir reports whether the go tool ignores the directory:
+// vendor, testdata and the ones starting with . or _.
+func skipDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+type rewriteJob struct {
+	path string
+	text bool
+}
+
+// walk rewrites every go file under dir, whatever its build constraints are.
+// nested modules and the directories ignored by the go tool are skipped.
+// the paths found by the walk are streamed to a bounded pool of workers.
+func (r *rewriter) walk(dir string) error {
+	workers := r.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	jobs := make(chan rewriteJob, workers)
+	done := make(chan struct{})
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		ferr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			ferr = err
+			close(done)
+		})
+	}
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				handle := r.file
+				if job.text {
+					handle = r.textFile
+				}
+
+				if err := handle(job.path); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	send := func(job rewriteJob) error {
+		select {
+		case jobs <- job:
+			return nil
+		case <-done:
+			// a worker failed, ferr is returned below.
+			return errStopWalk
+		}
+	}
+
+	werr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Println("import rewrite: ", err)
+			r.fail(err)
 			return nil
 		}
 
-		if info.IsDir() {
-			if info.Name() == "vendor" || info.Name() == ".git" || info.Name() == ".vscode" {
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+
+			if skipDir(d.Name()) {
+				r.skip(path)
 				return filepath.SkipDir
 			}
-			if path != dir {
-				_, err := os.Lstat(filepath.Join(path, "go.mod"))
-				if err == nil {
-					return filepath.SkipDir
-				}
-				if !os.IsNotExist(err) {
-					log.Panicln("import rewrite: ", err)
-					return nil
-				}
+
+			_, err := os.Lstat(filepath.Join(path, "go.mod"))
+			if err == nil {
+				r.skip(path)
+				return filepath.SkipDir
+			}
+			if !os.IsNotExist(err) {
+				r.fail(err)
+				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// only do rewrite in on go file.
 		if strings.HasSuffix(path, ".go") {
-			return rewriteFile(path, replace)
+			return send(rewriteJob{path: path})
+		}
+
+		if r.text != nil {
+			if rel, err := filepath.Rel(dir, path); err == nil && r.text.match(rel) {
+				return send(rewriteJob{path: path, text: true})
+			}
 		}
 
 		return nil
 	})
+
+	close(jobs)
+	wg.Wait()
+
+	if ferr != nil {
+		return ferr
+	}
+
+	return werr
 }
 
-func rewriteFile(name string, replace replaceFunc) error {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+func (r *rewriter) count(changed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if changed {
+		r.stats.rewritten++
+	} else {
+		r.stats.unchanged++
+	}
+}
+
+func (r *rewriter) skip(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.skipped = append(r.stats.skipped, path)
+}
+
+func (r *rewriter) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.failed = append(r.stats.failed, err)
+}
+
+// mentions reports whether src contains any of the prefixes, so it is worth parsing.
+func (r *rewriter) mentions(src []byte) bool {
+	if r.prefixes == nil {
+		return true
+	}
+
+	for _, p := range r.prefixes {
+		if bytes.Contains(src, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rewriter) file(name string) error {
+	src, err := ioutil.ReadFile(name)
 	if err != nil {
-		e := err.Error()
-		msg := "expected 'package'. found EOF"
-		if e[len(e)-len(msg):] == msg {
+		return err
+	}
+
+	if !r.mentions(src) {
+		r.count(false)
+		return nil
+	}
+
+	out, changed, err := rewriteSource(name, src, r.replace, r.names)
+	if err != nil {
+		if _, ok := err.(scanner.ErrorList); ok {
+			r.fail(err)
 			return nil
 		}
 
 		return err
 	}
 
-	change := false
+	if !changed {
+		out = src
+	}
+
+	if r.text != nil {
+		if text, ok := r.text.rewriteGo(name, out); ok {
+			out, changed = text, true
+		}
+	}
+
+	r.count(changed)
+	if !changed {
+		return nil
+	}
+
+	return r.w.write(name, out)
+}
+
+func (r *rewriter) textFile(name string) error {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if !r.mentions(src) {
+		r.count(false)
+		return nil
+	}
+
+	out, ok := r.text.rewriteText(name, src)
+	r.count(ok)
+	if !ok {
+		return nil
+	}
+
+	return r.w.write(name, out)
+}
+
+// patch replaces src[start:end] with text.
+type patch struct {
+	start, end int
+	text       string
+}
+
+// applyPatches applies non-overlapping patches sorted by offset.
+func applyPatches(src []byte, patches []patch) []byte {
+	out := make([]byte, 0, len(src))
+	last := 0
+	for _, p := range patches {
+		out = append(out, src[last:p.start]...)
+		out = append(out, p.text...)
+		last = p.end
+	}
+
+	return append(out, src[last:]...)
+}
+
+// rewriteSource rewrites the import paths of a go source file.
+// only the bytes of the changed import path literals are patched, everything
+// else is kept byte for byte, and import groups are re-sorted only when
+// the new paths leave them unsorted, as gofmt would do.
+// when names is not nil and the package name behind a path changes, the old
+// name is kept with an import alias, or the references are renamed.
+func rewriteSource(name string, src []byte, replace replaceFunc, names *pkgNames) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, false, err
+	}
+
+	patches := make([]patch, 0)
+	renames := make(map[string]string)
 	for _, i := range f.Imports {
 		path, err := strconv.Unquote(i.Path.Value)
 		if err != nil {
-			return err
+			return nil, false, err
 		}
 
-		path, err = replace(fset.Position(i.Pos()), path)
+		newp, err := replace(fset.Position(i.Pos()), path)
 		if err != nil {
 			if err == filepath.SkipDir {
 				continue
 			}
-			return err
+			return nil, false, err
+		}
+
+		if newp == path {
+			continue
 		}
 
-		i.Path.Value = strconv.Quote(path)
-		change = true
+		lit := strconv.Quote(newp)
+		if strings.HasPrefix(i.Path.Value, "`") {
+			lit = "`" + newp + "`"
+		}
+
+		if names != nil && i.Name == nil {
+			oldName, newName := names.names(path, newp)
+			switch {
+			case oldName == newName:
+			case names.rename:
+				renames[oldName] = newName
+			default:
+				lit = oldName + " " + lit
+			}
+		}
+
+		patches = append(patches, patch{
+			start: fset.Position(i.Path.Pos()).Offset,
+			end:   fset.Position(i.Path.End()).Offset,
+			text:  lit,
+		})
 	}
 
-	if !change {
-		return nil
+	if len(patches) == 0 {
+		return nil, false, nil
+	}
+
+	out := applyPatches(src, patches)
+	if len(renames) > 0 {
+		if out, err = renameRefs(name, out, renames); err != nil {
+			return nil, false, err
+		}
 	}
 
-	tmp := name + ".temp"
-	w, err := os.Create(tmp)
+	out, err = sortImports(name, out)
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	defer w.Close()
-	info, err := os.Lstat(name)
+	return out, true, nil
+}
+
+// sortImports sorts the runs of import specs which are not sorted, like gofmt does.
+// the specs are sorted by moving their lines, with their doc comments, so the
+// rest of the file is left byte for byte. a run which can't be sorted this way,
+// like specs sharing a line, is left as it is.
+func sortImports(name string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly|parser.ParseComments)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := w.Chmod(info.Mode()); err != nil {
-		return err
+	tf := fset.File(f.Pos())
+	line := func(pos token.Pos) int {
+		return fset.Position(pos).Line
 	}
 
-	cfg := &printer.Config{
-		Mode:     printer.TabIndent | printer.UseSpaces,
-		Tabwidth: 4,
+	// the first line of a spec, its doc comment included.
+	start := func(s ast.Spec) int {
+		if doc := s.(*ast.ImportSpec).Doc; doc != nil {
+			return line(doc.Pos())
+		}
+		return line(s.Pos())
 	}
-	if err := cfg.Fprint(w, fset, f); err != nil {
-		return err
+
+	patches := make([]patch, 0)
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			break
+		}
+
+		if !d.Lparen.IsValid() {
+			continue
+		}
+
+		// specs separated by a blank line are sorted separately.
+		i := 0
+		for j, s := range d.Specs {
+			if j > i && start(s) > 1+line(d.Specs[j-1].End()) {
+				patches = append(patches, sortRun(tf, line, start, src, d.Specs[i:j])...)
+				i = j
+			}
+		}
+
+		patches = append(patches, sortRun(tf, line, start, src, d.Specs[i:])...)
 	}
 
-	return os.Rename(tmp, name)
+	return applyPatches(src, patches), nil
 }
 
-func upgrade(modp, v, dir string, r, tidy bool) error {
-	newp := joinPath(modp, v, "")
+// sortRun returns the patch sorting a run of import specs, if they are not sorted
+// and each of them owns its lines.
+func sortRun(tf *token.File, line func(token.Pos) int, start func(ast.Spec) int, src []byte, specs []ast.Spec) []patch {
+	key := func(s ast.Spec) string {
+		i := s.(*ast.ImportSpec)
+		name := ""
+		if i.Name != nil {
+			name = i.Name.Name
+		}
 
-	// use go mod edit to update go.mod
-	if err := exec.Command("go", "get", "-u", fmt.Sprintf("%s@%s", newp, v)).Run(); err != nil {
-		return err
+		path, _ := strconv.Unquote(i.Path.Value)
+		return path + "\x00" + name
 	}
 
-	major, ok := modMajor(newp)
-	if !ok {
-		return fmt.Errorf("%s: failed to get major version", newp)
+	sorted := sort.SliceIsSorted(specs, func(i, j int) bool {
+		return key(specs[i]) < key(specs[j])
+	})
+	if sorted {
+		return nil
 	}
 
-	if !r || major == "v0" || major == "v1" || strings.Contains(v, "+incompatible") {
-		return nil
+	type specLines struct {
+		key  string
+		text string
 	}
-	// rewrite import path
-	err := rewrite(dir, func(_ token.Position, path string) (string, error) {
-		_, pkgdir, ok := splitPath(modp, path)
-		if !ok {
-			return "", filepath.SkipDir
+
+	first, next := start(specs[0]), start(specs[0])
+	blocks := make([]specLines, 0, len(specs))
+	for _, s := range specs {
+		from, to := start(s), line(s.End())
+		if from != next || to >= tf.LineCount() {
+			return nil
 		}
-		newp := joinPath(modp, v, pkgdir)
+		next = to + 1
 
-		return newp, nil
-	})
-	if err != nil {
-		return err
+		blocks = append(blocks, specLines{
+			key:  key(s),
+			text: string(src[tf.Offset(tf.LineStart(from)):tf.Offset(tf.LineStart(next))]),
+		})
 	}
 
-	// after rewrite, we need to run go mod tidy to make sure go.mod is valid. ?
-	if tidy {
-		if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
-			return err
-		}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].key < blocks[j].key
+	})
+
+	var sb strings.Builder
+	for _, b := range blocks {
+		sb.WriteString(b.text)
 	}
-	
 
-	return nil
+	return []patch{{
+		start: tf.Offset(tf.LineStart(first)),
+		end:   tf.Offset(tf.LineStart(next)),
+		text:  sb.String(),
+	}}
 }
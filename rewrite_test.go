@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteSource(t *testing.T) {
+	replace := func(_ token.Position, path string) (string, error) {
+		if !strings.HasPrefix(path, "example.com/a") {
+			return "", filepath.SkipDir
+		}
+		return "example.com/z" + strings.TrimPrefix(path, "example.com/a"), nil
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		changed bool
+	}{
+		{
+			name:    "untouched",
+			src:     "package p\n\nimport \"fmt\"\n",
+			changed: false,
+		},
+		{
+			name: "keep formatting",
+			src:  "package p\n\nimport   foo   \"example.com/a/foo\" // foo\n\nfunc  F( )  {foo.F()}\n",
+			want: "package p\n\nimport   foo   \"example.com/z/foo\" // foo\n\nfunc  F( )  {foo.F()}\n",
+		},
+		{
+			name: "raw string",
+			src:  "package p\n\nimport `example.com/a`\n",
+			want: "package p\n\nimport `example.com/z`\n",
+		},
+		{
+			name: "still sorted",
+			src:  "package p\n\nimport (\n\t\"example.com/a\"\n\t\"fmt\"\n)\n",
+			want: "package p\n\nimport (\n\t\"example.com/z\"\n\t\"fmt\"\n)\n",
+		},
+		{
+			name: "resort group",
+			src:  "package p\n\nimport (\n\t\"example.com/a\" // a\n\t\"example.com/b\"\n\n\t\"fmt\"\n)\n\nvar  x = 1\n",
+			want: "package p\n\nimport (\n\t\"example.com/b\"\n\t\"example.com/z\" // a\n\n\t\"fmt\"\n)\n\nvar  x = 1\n",
+		},
+		{
+			// the doc comment moves with its import, the rest is kept byte for byte.
+			name: "resort with doc comment",
+			src:  "package p\n\nimport (\n\t// a is\n\t// documented\n\t\"example.com/a\"\n\t\"example.com/b\" // b\n\n\t\"fmt\"\n)\n\nfunc  F( )  {\n\tvar  x=[]int{1,2}\n\t_ = x\n}\n",
+			want: "package p\n\nimport (\n\t\"example.com/b\" // b\n\t// a is\n\t// documented\n\t\"example.com/z\"\n\n\t\"fmt\"\n)\n\nfunc  F( )  {\n\tvar  x=[]int{1,2}\n\t_ = x\n}\n",
+		},
+		{
+			// can't be sorted by moving lines, left unsorted rather than reformatted.
+			name: "specs sharing a line",
+			src:  "package p\n\nimport (\n\t\"example.com/a\"; \"example.com/b\"\n)\n\nvar  x = 1\n",
+			want: "package p\n\nimport (\n\t\"example.com/z\"; \"example.com/b\"\n)\n\nvar  x = 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := rewriteSource("p.go", []byte(tt.src), replace, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want != "", changed)
+			if changed {
+				assert.Equal(t, tt.want, string(got))
+			}
+		})
+	}
+}
+
+func TestRewriterWalk(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":              "package p\n\nimport \"example.com/a\"\n",
+		"a_windows.go":      "//go:build windows\n\npackage p\n\nimport \"example.com/a\"\n",
+		"gen.go":            "//go:build ignore\n\npackage main\n\nimport \"example.com/a/gen\"\n",
+		"other.go":          "package p\n\nimport \"fmt\"\n",
+		"broken.go":         "package p\n\nimport (\n",
+		"testdata/t.go":     "package {{.Name}}\n\nimport \"example.com/a\"\n",
+		"_old/o.go":         "package o\n\nimport \"example.com/a\"\n",
+		".cache/c.go":       "package c\n\nimport \"example.com/a\"\n",
+		"sub/go.mod":        "module example.com/sub\n",
+		"sub/s.go":          "package s\n\nimport \"example.com/a\"\n",
+		"internal/x/x.go":   "package x\n\nimport \"example.com/a\"\n",
+		"internal/x/README": "example.com/a\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+
+	w := newMemWriter()
+	r := &rewriter{
+		replace: func(_ token.Position, path string) (string, error) {
+			if !strings.HasPrefix(path, "example.com/a") {
+				return "", filepath.SkipDir
+			}
+			return "example.com/z" + strings.TrimPrefix(path, "example.com/a"), nil
+		},
+		w: w,
+	}
+	assert.Nil(t, r.walk(dir))
+
+	rewritten := make([]string, 0)
+	for name := range w.files {
+		rel, _ := filepath.Rel(dir, name)
+		rewritten = append(rewritten, filepath.ToSlash(rel))
+	}
+	sort.Strings(rewritten)
+
+	assert.Equal(t, []string{"a.go", "a_windows.go", "gen.go", "internal/x/x.go"}, rewritten)
+	assert.Equal(t, 4, r.stats.rewritten)
+	assert.Equal(t, 1, r.stats.unchanged)
+	assert.Len(t, r.stats.skipped, 4)
+	assert.Len(t, r.stats.failed, 1)
+	assert.Contains(t, r.stats.failed[0].Error(), "broken.go")
+}
+
+// genTree writes n go files spread over directories, every tenth of them imports example.com/a.
+func genTree(tb testing.TB, n int) string {
+	dir := tb.TempDir()
+	for i := 0; i < n; i++ {
+		pkg := filepath.Join(dir, fmt.Sprintf("pkg%d", i/50))
+		if err := os.MkdirAll(pkg, 0755); err != nil {
+			tb.Fatal(err)
+		}
+
+		imp := "fmt"
+		if i%10 == 0 {
+			imp = "example.com/a/sub"
+		}
+
+		src := fmt.Sprintf("package p\n\nimport (\n\t\"os\"\n\t%q\n)\n\nvar _ = os.Args\n\nfunc F%d() {}\n", imp, i)
+		if err := ioutil.WriteFile(filepath.Join(pkg, fmt.Sprintf("f%d.go", i)), []byte(src), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func moveA(_ token.Position, path string) (string, error) {
+	if !strings.HasPrefix(path, "example.com/a") {
+		return "", filepath.SkipDir
+	}
+	return "example.com/a/v2" + strings.TrimPrefix(path, "example.com/a"), nil
+}
+
+func TestRewriterPrefixes(t *testing.T) {
+	dir := genTree(t, 200)
+
+	w := newMemWriter()
+	r := &rewriter{replace: moveA, w: w, prefixes: [][]byte{[]byte("example.com/a")}}
+	assert.Nil(t, r.walk(dir))
+	assert.Len(t, w.files, 20)
+	assert.Equal(t, 20, r.stats.rewritten)
+	assert.Equal(t, 180, r.stats.unchanged)
+}
+
+func BenchmarkRewrite(b *testing.B) {
+	dir := genTree(b, 5000)
+
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := &rewriter{replace: moveA, w: newMemWriter(), workers: workers, prefixes: [][]byte{[]byte("example.com/a")}}
+				if err := r.walk(dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("parse all", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r := &rewriter{replace: moveA, w: newMemWriter()}
+			if err := r.walk(dir); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
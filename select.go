@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	"github.com/AlecAivazis/survey/v2/terminal"
+)
+
+// detailSelect is survey's MultiSelect prompt, which can also show details about
+// the focused option, e.g. the release notes of a dependency, with the help key.
+// the details follow the focus until the key is pressed again.
+// Help tells what the details are, in the "? for ..." help line.
+type detailSelect struct {
+	survey.MultiSelect
+	// Details returns the details of the option at index. it is called once per
+	// option, in the background, the details are shown when it returns.
+	Details func(index int) string
+
+	// mu guards the state below, the details are loaded by other goroutines.
+	mu      sync.Mutex
+	config  *survey.PromptConfig
+	filter  string
+	focused int
+	checked map[int]bool
+	showing bool
+	details map[int]string
+	loading map[int]bool
+}
+
+// loadingDetails is shown while the details of the focused option are loaded.
+const loadingDetails = "loading..."
+
+// OnChange is called on every keypress.
+func (s *detailSelect) OnChange(key rune, config *survey.PromptConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.render(s.handle(key, config), config)
+}
+
+// handle updates the state of the prompt for a keypress and returns the options left by the filter.
+func (s *detailSelect) handle(key rune, config *survey.PromptConfig) []core.OptionAnswer {
+	options := s.filterOptions(config)
+
+	switch {
+	case key == terminal.KeyArrowUp:
+		s.focused--
+		if s.focused < 0 {
+			s.focused = len(options) - 1
+		}
+	case key == terminal.KeyTab || key == terminal.KeyArrowDown:
+		s.focused++
+		if s.focused >= len(options) {
+			s.focused = 0
+		}
+	case key == terminal.KeySpace:
+		if s.focused < len(options) {
+			idx := options[s.focused].Index
+			s.checked[idx] = !s.checked[idx]
+			if !config.KeepFilter {
+				s.filter = ""
+			}
+		}
+	case string(key) == config.HelpInput && s.Details != nil:
+		s.showing = !s.showing
+	case key == terminal.KeyDeleteWord || key == terminal.KeyDeleteLine:
+		s.filter = ""
+	case key == terminal.KeyDelete || key == terminal.KeyBackspace:
+		if s.filter != "" {
+			runes := []rune(s.filter)
+			s.filter = string(runes[:len(runes)-1])
+		}
+	case key >= terminal.KeySpace:
+		s.filter += string(key)
+	case key == terminal.KeyArrowRight || key == terminal.KeyArrowLeft:
+		for _, opt := range options {
+			s.checked[opt.Index] = key == terminal.KeyArrowRight
+		}
+		if !config.KeepFilter {
+			s.filter = ""
+		}
+	}
+
+	// the focus stays on an option, or on 0 when the filter matches none.
+	options = s.filterOptions(config)
+	if s.focused >= len(options) {
+		s.focused = len(options) - 1
+	}
+	if s.focused < 0 {
+		s.focused = 0
+	}
+
+	return options
+}
+
+func (s *detailSelect) render(options []core.OptionAnswer, config *survey.PromptConfig) error {
+	pageSize := s.PageSize
+	if pageSize == 0 {
+		pageSize = config.PageSize
+	}
+
+	opts, idx := paginate(pageSize, options, s.focused)
+
+	data := survey.MultiSelectTemplateData{
+		MultiSelect:   s.MultiSelect,
+		SelectedIndex: idx,
+		Checked:       s.checked,
+		PageEntries:   opts,
+		Config:        config,
+	}
+
+	data.FilterMessage = ""
+	if s.filter != "" {
+		data.FilterMessage = " " + s.filter
+	}
+
+	if s.showing && s.focused < len(options) {
+		data.Help = s.detailsOf(options[s.focused].Index)
+		data.ShowHelp = true
+	}
+
+	return s.RenderWithCursorOffset(survey.MultiSelectQuestionTemplate, data, opts, idx)
+}
+
+// detailsOf returns the details of an option, or loadingDetails while they are
+// loaded. the prompt is rendered again once they are, if they are still showing.
+func (s *detailSelect) detailsOf(index int) string {
+	if d, ok := s.details[index]; ok {
+		return d
+	}
+
+	if !s.loading[index] {
+		s.loading[index] = true
+		go func() {
+			d := s.Details(index)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			s.details[index] = d
+			if s.showing && s.config != nil {
+				_ = s.render(s.filterOptions(s.config), s.config)
+			}
+		}()
+	}
+
+	return loadingDetails
+}
+
+func (s *detailSelect) filterOptions(config *survey.PromptConfig) []core.OptionAnswer {
+	if s.filter == "" {
+		return core.OptionAnswerList(s.Options)
+	}
+
+	filter := s.Filter
+	if filter == nil {
+		filter = config.Filter
+	}
+
+	answers := make([]core.OptionAnswer, 0)
+	for i, opt := range s.Options {
+		if filter(s.filter, opt, i) {
+			answers = append(answers, core.OptionAnswer{Index: i, Value: opt})
+		}
+	}
+
+	return answers
+}
+
+// reset starts the prompt with nothing checked and the focus on the first option.
+func (s *detailSelect) reset() {
+	s.filter = ""
+	s.focused = 0
+	s.checked = make(map[int]bool)
+	s.showing = false
+	s.details = make(map[int]string)
+	s.loading = make(map[int]bool)
+}
+
+func (s *detailSelect) Prompt(config *survey.PromptConfig) (interface{}, error) {
+	if len(s.Options) == 0 {
+		return nil, errors.New("please provide options to select from")
+	}
+
+	s.mu.Lock()
+	s.reset()
+	s.config = config
+	s.mu.Unlock()
+
+	// the details loaded after the prompt is answered are not rendered.
+	defer func() {
+		s.mu.Lock()
+		s.config = nil
+		s.mu.Unlock()
+	}()
+
+	cursor := s.NewCursor()
+	cursor.Save()
+	cursor.Hide()
+	defer cursor.Show()
+	defer cursor.Restore()
+
+	s.mu.Lock()
+	err := s.render(core.OptionAnswerList(s.Options), config)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	rr := s.NewRuneReader()
+	_ = rr.SetTermMode()
+	defer func() {
+		_ = rr.RestoreTermMode()
+	}()
+
+	for {
+		r, _, err := rr.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		if r == '\r' || r == '\n' || r == terminal.KeyEndTransmission {
+			break
+		}
+		if r == terminal.KeyInterrupt {
+			return nil, terminal.InterruptErr
+		}
+		s.OnChange(r, config)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answers := make([]core.OptionAnswer, 0)
+	for i, opt := range s.Options {
+		if s.checked[i] {
+			answers = append(answers, core.OptionAnswer{Index: i, Value: opt})
+		}
+	}
+
+	return answers, nil
+}
+
+// Cleanup disables the answer output.
+func (s *detailSelect) Cleanup(config *survey.PromptConfig, val interface{}) error {
+	return s.Render("", nil)
+}
+
+// paginate returns the page of options around the focused one and its index in the page.
+func paginate(pageSize int, options []core.OptionAnswer, focused int) ([]core.OptionAnswer, int) {
+	switch {
+	case len(options) < pageSize || focused < pageSize/2:
+		end := pageSize
+		if end > len(options) {
+			end = len(options)
+		}
+		return options[:end], focused
+	case len(options)-focused-1 < pageSize/2:
+		start := len(options) - pageSize
+		return options[start:], focused - start
+	default:
+		start := focused - pageSize/2
+		return options[start : start+pageSize], pageSize / 2
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDetailSelect(details func(int) string) (*detailSelect, *survey.PromptConfig) {
+	s := &detailSelect{
+		MultiSelect: survey.MultiSelect{Options: []string{"github.com/a/foo", "github.com/b/bar", "github.com/c/foobar"}},
+		Details:     details,
+	}
+	s.reset()
+
+	config := &survey.PromptConfig{
+		HelpInput: "?",
+		Filter: func(filter, value string, _ int) bool {
+			return strings.Contains(value, filter)
+		},
+	}
+
+	return s, config
+}
+
+func values(options []core.OptionAnswer) []string {
+	vs := make([]string, 0, len(options))
+	for _, opt := range options {
+		vs = append(vs, opt.Value)
+	}
+
+	return vs
+}
+
+func TestDetailSelectNavigation(t *testing.T) {
+	s, config := newDetailSelect(nil)
+
+	s.handle(terminal.KeyArrowUp, config)
+	assert.Equal(t, 2, s.focused)
+
+	s.handle(terminal.KeyArrowDown, config)
+	assert.Equal(t, 0, s.focused)
+
+	s.handle(terminal.KeyTab, config)
+	assert.Equal(t, 1, s.focused)
+}
+
+func TestDetailSelectFilter(t *testing.T) {
+	s, config := newDetailSelect(nil)
+	s.focused = 2
+
+	var options []core.OptionAnswer
+	for _, key := range "foo" {
+		options = s.handle(key, config)
+	}
+	assert.Equal(t, []string{"github.com/a/foo", "github.com/c/foobar"}, values(options))
+	// the focus stays in the filtered options.
+	assert.Equal(t, 1, s.focused)
+
+	options = s.handle(terminal.KeyBackspace, config)
+	assert.Equal(t, "fo", s.filter)
+	assert.Len(t, options, 2)
+
+	// nothing matches, the keys must not move the focus out of the options.
+	options = s.handle('x', config)
+	assert.Empty(t, options)
+	for _, key := range []rune{terminal.KeyArrowUp, terminal.KeyArrowDown, terminal.KeySpace, terminal.KeyArrowRight} {
+		s.handle(key, config)
+		assert.Equal(t, 0, s.focused)
+	}
+	assert.Empty(t, s.checked)
+
+	options = s.handle(terminal.KeyDeleteLine, config)
+	assert.Equal(t, "", s.filter)
+	assert.Len(t, options, 3)
+}
+
+func TestDetailSelectToggle(t *testing.T) {
+	s, config := newDetailSelect(nil)
+
+	s.handle(terminal.KeySpace, config)
+	assert.True(t, s.checked[0])
+	s.handle(terminal.KeySpace, config)
+	assert.False(t, s.checked[0])
+
+	// the right arrow checks the filtered options and clears the filter.
+	s.handle('b', config)
+	s.handle('a', config)
+	s.handle(terminal.KeyArrowRight, config)
+	assert.Equal(t, map[int]bool{0: false, 1: true, 2: true}, s.checked)
+	assert.Equal(t, "", s.filter)
+
+	s.handle(terminal.KeyArrowLeft, config)
+	assert.Equal(t, map[int]bool{0: false, 1: false, 2: false}, s.checked)
+}
+
+func TestDetailSelectDetails(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	s, config := newDetailSelect(func(i int) string {
+		calls++
+		<-release
+		return []string{"foo notes", "bar notes", "foobar notes"}[i]
+	})
+
+	// the help key shows the details, hidden by default.
+	s.handle('?', config)
+	assert.True(t, s.showing)
+
+	s.mu.Lock()
+	assert.Equal(t, loadingDetails, s.detailsOf(0))
+	assert.Equal(t, loadingDetails, s.detailsOf(0))
+	s.mu.Unlock()
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.details[0] != ""
+	}, time.Second, time.Millisecond)
+
+	s.mu.Lock()
+	assert.Equal(t, "foo notes", s.detailsOf(0))
+	s.mu.Unlock()
+	assert.Equal(t, 1, calls)
+
+	s.handle('?', config)
+	assert.False(t, s.showing)
+}
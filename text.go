@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// defaultTextFiles are the files checked for module paths by --rewrite-text.
+var defaultTextFiles = []string{"*.proto", "buf*.yaml", "Dockerfile*", "Makefile", "*.mk", "*.md"}
+
+// replacement is a module path reference rewritten outside of the imports.
+type replacement struct {
+	file string
+	line int
+	old  string
+	new  string
+}
+
+// textReplacer rewrites references to moved module paths in comments of go files
+// (like //go:generate directives) and in text files, e.g. go_package options of
+// .proto files, Dockerfiles and Makefiles.
+type textReplacer struct {
+	// moved are sorted by module prefix from the longest to the shortest.
+	moved []version
+	globs []string
+
+	mu           sync.Mutex
+	replacements []replacement
+}
+
+func newTextReplacer(moved []version, globs []string) *textReplacer {
+	return &textReplacer{moved: moved, globs: globs}
+}
+
+// match reports whether the file, relative to the rewrite root, is a text file to rewrite.
+// globs without a slash are matched against the base name.
+func (t *textReplacer) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, g := range t.globs {
+		name := rel
+		if !strings.Contains(g, "/") {
+			name = path.Base(rel)
+		}
+
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rewriteGo rewrites the module paths in the comments of a go source.
+func (t *textReplacer) rewriteGo(name string, src []byte) ([]byte, bool) {
+	fset := token.NewFileSet()
+	f := fset.AddFile(name, -1, len(src))
+
+	var s scanner.Scanner
+	s.Init(f, src, nil, scanner.ScanComments)
+
+	patches := make([]patch, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		if tok == token.COMMENT {
+			start := f.Offset(pos)
+			patches = append(patches, t.find(src, start, start+len(lit))...)
+		}
+	}
+
+	return t.apply(name, src, patches)
+}
+
+// rewriteText rewrites the module paths anywhere in a text file.
+func (t *textReplacer) rewriteText(name string, src []byte) ([]byte, bool) {
+	return t.apply(name, src, t.find(src, 0, len(src)))
+}
+
+func (t *textReplacer) apply(name string, src []byte, patches []patch) ([]byte, bool) {
+	if len(patches) == 0 {
+		return nil, false
+	}
+
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].start < patches[j].start
+	})
+
+	t.mu.Lock()
+	for _, p := range patches {
+		t.replacements = append(t.replacements, replacement{
+			file: name,
+			line: 1 + bytes.Count(src[:p.start], []byte("\n")),
+			old:  string(src[p.start:p.end]),
+			new:  p.text,
+		})
+	}
+	t.mu.Unlock()
+
+	return applyPatches(src, patches), true
+}
+
+// find returns the patches for the references to moved modules in src[start:end].
+// a version right after the package path, like example.com/tool/v2/cmd@v2.1.0,
+// is moved to the new version as well when it has the old major.
+func (t *textReplacer) find(src []byte, start, end int) []patch {
+	patches := make([]patch, 0)
+	taken := func(i, j int) bool {
+		for _, p := range patches {
+			if i < p.end && p.start < j {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, v := range t.moved {
+		old, newp := []byte(v.mod), v.newPath()
+
+		for i := start; i < end; {
+			idx := bytes.Index(src[i:end], old)
+			if idx < 0 {
+				break
+			}
+
+			i += idx
+			j := i + len(old)
+			if !modPathAt(src[:end], i, j) || taken(i, j) {
+				i++
+				continue
+			}
+
+			// the package path and the version following the module path.
+			k := j
+			for k < end && (isPathChar(src[k]) || src[k] == '/') {
+				k++
+			}
+			text := newp + string(src[j:k])
+
+			if k < end && src[k] == '@' {
+				l := k + 1
+				for l < end && (isPathChar(src[l]) || src[l] == '+') {
+					l++
+				}
+
+				ver := string(src[k+1 : l])
+				if semver.IsValid(ver) && semver.Major(ver) == semver.Major(v.old) {
+					text += "@" + v.new
+					k = l
+				}
+			}
+
+			patches = append(patches, patch{start: i, end: k, text: text})
+			i = k
+		}
+	}
+
+	return patches
+}
+
+// modPathAt reports whether text[i:j] is a whole module path and not part of
+// a longer path, e.g. example.com/tool in example.com/toolbox, example.com/tool/v2
+// or https://example.com/tool.
+func modPathAt(text []byte, i, j int) bool {
+	if i > 0 && (isPathChar(text[i-1]) || text[i-1] == '/') {
+		return false
+	}
+
+	if j == len(text) {
+		return true
+	}
+
+	switch c := text[j]; {
+	case c == '.':
+		// the end of a sentence.
+		return j+1 == len(text) || !isPathChar(text[j+1]) && text[j+1] != '/'
+	case c == '/':
+		seg := text[j+1:]
+		if idx := bytes.IndexFunc(seg, func(r rune) bool { return r > 127 || !isPathChar(byte(r)) }); idx >= 0 {
+			seg = seg[:idx]
+		}
+		// another major version of the module.
+		return !isMajor(string(seg))
+	default:
+		return !isPathChar(c)
+	}
+}
+
+// isMajor reports whether the path element is a major version suffix like v2.
+func isMajor(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+
+	for _, c := range elem[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isPathChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextReplacer(t *testing.T) {
+	moved := newPlan([]version{
+		{path: "example.com/tool", mod: "example.com/tool/v2", old: "v2.1.0", new: "v3.0.0"},
+		{path: "example.com/lib", mod: "example.com/lib", old: "v1.2.0", new: "v2.0.0"},
+	}).moved
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "generate directive",
+			src:  "//go:generate go run example.com/tool/v2/cmd/gen@v2.1.0 -o x.go\n",
+			want: "//go:generate go run example.com/tool/v3/cmd/gen@v3.0.0 -o x.go\n",
+		},
+		{
+			name: "latest is kept",
+			src:  "go install example.com/tool/v2/cmd/gen@latest\n",
+			want: "go install example.com/tool/v3/cmd/gen@latest\n",
+		},
+		{
+			name: "go_package",
+			src:  "option go_package = \"example.com/lib/gen/pb;pb\";\n",
+			want: "option go_package = \"example.com/lib/v2/gen/pb;pb\";\n",
+		},
+		{
+			name: "end of sentence",
+			src:  "see example.com/lib.\n",
+			want: "see example.com/lib/v2.\n",
+		},
+		{
+			name: "not a module path",
+			src:  "https://example.com/lib example.com/library example.com/lib/v5 example.com/tool/v20\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTextReplacer(moved, nil)
+			got, ok := r.rewriteText("README.md", []byte(tt.src))
+			assert.Equal(t, tt.want != "", ok)
+			if ok {
+				assert.Equal(t, tt.want, string(got))
+				assert.Len(t, r.replacements, 1)
+				assert.Equal(t, 1, r.replacements[0].line)
+			}
+		})
+	}
+}
+
+func TestTextReplacerGo(t *testing.T) {
+	moved := newPlan([]version{
+		{path: "example.com/tool", mod: "example.com/tool/v2", old: "v2.1.0", new: "v3.0.0"},
+	}).moved
+
+	src := "package p\n\n//go:generate go run example.com/tool/v2/gen\n\nconst s = \"example.com/tool/v2\"\n"
+	want := "package p\n\n//go:generate go run example.com/tool/v3/gen\n\nconst s = \"example.com/tool/v2\"\n"
+
+	r := newTextReplacer(moved, nil)
+	got, ok := r.rewriteGo("p.go", []byte(src))
+	assert.True(t, ok)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, []replacement{{file: "p.go", line: 3, old: "example.com/tool/v2/gen", new: "example.com/tool/v3/gen"}}, r.replacements)
+}
+
+func TestTextReplacerMatch(t *testing.T) {
+	r := newTextReplacer(nil, append(defaultTextFiles, "deploy/*.yaml"))
+	assert.True(t, r.match("api/v1/api.proto"))
+	assert.True(t, r.match("Dockerfile.dev"))
+	assert.True(t, r.match("deploy/app.yaml"))
+	assert.False(t, r.match("other/app.yaml"))
+	assert.False(t, r.match("main.go"))
+}
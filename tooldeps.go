@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// toolDep is a tool the module depends on, declared by a tool directive of go.mod
+// (go 1.24) or by a blank import in a tools.go file behind the tools build tag.
+type toolDep struct {
+	// pkg is the package path of the tool.
+	pkg string
+	// file is the tools.go file, empty for a tool directive.
+	file string
+}
+
+// toolDirectives returns the packages of the tool directives of a go.mod file.
+// they are read from the syntax, as the lax parser skips them.
+func toolDirectives(f *modfile.File) []string {
+	pkgs := make([]string, 0)
+	for _, stmt := range f.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if len(x.Token) == 2 && x.Token[0] == "tool" {
+				pkgs = append(pkgs, unquoteModPath(x.Token[1]))
+			}
+		case *modfile.LineBlock:
+			if len(x.Token) != 1 || x.Token[0] != "tool" {
+				continue
+			}
+
+			for _, line := range x.Line {
+				if len(line.Token) == 1 {
+					pkgs = append(pkgs, unquoteModPath(line.Token[0]))
+				}
+			}
+		}
+	}
+
+	return pkgs
+}
+
+func unquoteModPath(s string) string {
+	if p, err := strconv.Unquote(s); err == nil {
+		return p
+	}
+
+	return s
+}
+
+// toolsFiles finds the go files of the module at root only built with the tools tag,
+// and returns their blank imports.
+func toolsFiles(root string) ([]toolDep, error) {
+	tools := make([]toolDep, 0)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+
+			if skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+
+			// a nested module has its own tools.
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Contains(src, []byte("tools")) {
+			return nil
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.ImportsOnly|parser.ParseComments)
+		if err != nil || !toolsOnly(f.Comments, f.Package) {
+			return nil
+		}
+
+		for _, spec := range f.Imports {
+			if spec.Name != nil && spec.Name.Name == "_" {
+				tools = append(tools, toolDep{pkg: unquoteModPath(spec.Path.Value), file: path})
+			}
+		}
+
+		return nil
+	})
+
+	return tools, err
+}
+
+// toolsOnly reports whether the build constraint of a file before its package clause
+// is only satisfied with the tools tag, like //go:build tools.
+func toolsOnly(comments []*ast.CommentGroup, pkg token.Pos) bool {
+	for _, g := range comments {
+		if g.Pos() > pkg {
+			break
+		}
+
+		for _, c := range g.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				continue
+			}
+
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				continue
+			}
+
+			with := expr.Eval(func(tag string) bool { return tag == "tools" })
+
+			return with && !satisfiable(expr, "tools")
+		}
+	}
+
+	return false
+}
+
+// satisfiable reports whether some set of tags other than tag satisfies the expression,
+// e.g. linux || tools is built on linux without the tools tag.
+func satisfiable(expr constraint.Expr, tag string) bool {
+	tags := make([]string, 0)
+	seen := map[string]bool{tag: true}
+	expr.Eval(func(t string) bool {
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+		return false
+	})
+
+	// build constraints are short, give up on the unusual ones.
+	if len(tags) > 16 {
+		return true
+	}
+
+	for set := 0; set < 1<<len(tags); set++ {
+		on := make(map[string]bool, len(tags))
+		for i, t := range tags {
+			on[t] = set&(1<<i) != 0
+		}
+
+		if expr.Eval(func(t string) bool { return on[t] }) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toolRequirements returns the tools of the module at dir, and the requirements
+// backing them, the longest module path prefix of each tool package.
+func toolRequirements(dir string) ([]toolDep, []module.Version, error) {
+	name, err := findModFile(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tools := make([]toolDep, 0)
+	for _, pkg := range toolDirectives(f) {
+		tools = append(tools, toolDep{pkg: pkg})
+	}
+
+	files, err := toolsFiles(filepath.Dir(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	tools = append(tools, files...)
+
+	seen := make(map[string]bool)
+	mods := make([]module.Version, 0)
+	for _, tool := range tools {
+		var backing *modfile.Require
+		for _, req := range f.Require {
+			p := req.Mod.Path
+			if (tool.pkg == p || strings.HasPrefix(tool.pkg, p+"/")) && (backing == nil || len(p) > len(backing.Mod.Path)) {
+				backing = req
+			}
+		}
+
+		if backing != nil && !seen[backing.Mod.Path] {
+			seen[backing.Mod.Path] = true
+			mods = append(mods, backing.Mod)
+		}
+	}
+
+	return tools, mods, nil
+}
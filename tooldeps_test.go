@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/mod/module"
+)
+
+func TestToolRequirements(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": `module example.com/m
+
+go 1.24
+
+tool golang.org/x/tools/cmd/stringer
+
+tool (
+	github.com/golangci/golangci-lint/v2/cmd/golangci-lint
+)
+
+require (
+	github.com/golang/mock v1.6.0
+	github.com/golangci/golangci-lint/v2 v2.1.0 // indirect
+	golang.org/x/tools v0.30.0 // indirect
+	golang.org/x/tools/gopls v0.18.0 // indirect
+	github.com/google/go-cmp v0.5.9
+)
+`,
+		"tools/tools.go":    "//go:build tools\n\npackage tools\n\nimport (\n\t_ \"github.com/golang/mock/mockgen\"\n\t_ \"golang.org/x/tools/gopls\"\n)\n",
+		"main.go":           "package main\n\nimport _ \"github.com/google/go-cmp/cmp\" // not for tools\n",
+		"linux.go":          "//go:build linux || tools\n\npackage main\n\nimport _ \"github.com/google/go-cmp/cmp\"\n",
+		"nested/go.mod":     "module example.com/m/nested\n",
+		"nested/tools.go":   "//go:build tools\n\npackage nested\n\nimport _ \"example.com/nested/tool\"\n",
+		"testdata/tools.go": "//go:build tools\n\npackage testdata\n\nimport _ \"example.com/testdata/tool\"\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+
+	tools, mods, err := toolRequirements(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []toolDep{
+		{pkg: "golang.org/x/tools/cmd/stringer"},
+		{pkg: "github.com/golangci/golangci-lint/v2/cmd/golangci-lint"},
+		{pkg: "github.com/golang/mock/mockgen", file: filepath.Join(dir, "tools", "tools.go")},
+		{pkg: "golang.org/x/tools/gopls", file: filepath.Join(dir, "tools", "tools.go")},
+	}, tools)
+	assert.Equal(t, []module.Version{
+		{Path: "golang.org/x/tools", Version: "v0.30.0"},
+		{Path: "github.com/golangci/golangci-lint/v2", Version: "v2.1.0"},
+		{Path: "github.com/golang/mock", Version: "v1.6.0"},
+		{Path: "golang.org/x/tools/gopls", Version: "v0.18.0"},
+	}, mods)
+}
+
+func TestEditModFileTools(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "go.mod")
+	gomod := `module example.com/m
+
+go 1.24
+
+tool (
+	example.com/lint/v2/cmd/lint
+	golang.org/x/tools/cmd/stringer
+)
+
+require (
+	example.com/lint/v2 v2.1.0 // indirect
+	golang.org/x/tools v0.30.0 // indirect
+)
+`
+	assert.Nil(t, ioutil.WriteFile(name, []byte(gomod), 0644))
+
+	versions := []version{
+		{path: "example.com/lint", mod: "example.com/lint/v2", old: "v2.1.0", new: "v3.0.0", tool: true},
+		{path: "golang.org/x/tools", mod: "golang.org/x/tools", old: "v0.30.0", new: "v0.31.0", tool: true},
+	}
+	data, err := editModFile(name, versions, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `module example.com/m
+
+go 1.24
+
+tool (
+	example.com/lint/v3/cmd/lint
+	golang.org/x/tools/cmd/stringer
+)
+
+require (
+	example.com/lint/v3 v3.0.0
+	golang.org/x/tools v0.31.0 // indirect
+)
+`, string(data))
+}
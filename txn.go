@@ -0,0 +1,415 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// snapshot is the content of a file before the upgrade touched it.
+type snapshot struct {
+	Path   string      `json:"path"`
+	Exists bool        `json:"exists"`
+	Mode   fs.FileMode `json:"mode"`
+	Data   []byte      `json:"data,omitempty"`
+	// Sum is the sha256 of the file after the upgrade, used by undo to
+	// make sure the file was not changed since.
+	Sum string `json:"sum,omitempty"`
+}
+
+// txn records every file an upgrade touches, so that all of them can be
+// restored when a step fails, the user interrupts, or asks for an undo.
+type txn struct {
+	mu    sync.Mutex
+	root  string
+	files map[string]*snapshot
+	// vendor is the vendor directory moved away while go mod vendor regenerates it.
+	vendor *dirBackup
+	sig    chan os.Signal
+	done   chan struct{}
+	stop   int32
+}
+
+// dirBackup is a directory renamed to backup, empty if the directory did not exist.
+type dirBackup struct {
+	path   string
+	backup string
+}
+
+// newTxn starts a transaction for the module at root.
+// it catches Ctrl-C until close is called.
+func newTxn(root string) *txn {
+	t := &txn{
+		root:  root,
+		files: make(map[string]*snapshot),
+		sig:   make(chan os.Signal, 1),
+		done:  make(chan struct{}),
+	}
+	signal.Notify(t.sig, os.Interrupt)
+
+	go func() {
+		select {
+		case <-t.sig:
+			atomic.StoreInt32(&t.stop, 1)
+		case <-t.done:
+		}
+	}()
+
+	return t
+}
+
+func (t *txn) close() {
+	signal.Stop(t.sig)
+	close(t.done)
+}
+
+// interrupted reports whether the user hit Ctrl-C during the transaction.
+func (t *txn) interrupted() bool {
+	return atomic.LoadInt32(&t.stop) == 1
+}
+
+// check returns errInterrupted once the user hit Ctrl-C, the steps of an
+// upgrade call it before they start so that nothing runs after an interrupt.
+func (t *txn) check() error {
+	if t.interrupted() {
+		return errInterrupted
+	}
+
+	return nil
+}
+
+// save snapshots the given files, only the first snapshot of a file is kept.
+func (t *txn) save(names ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, name := range names {
+		if err := t.saveLocked(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *txn) saveLocked(name string) error {
+	name, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := t.files[name]; ok {
+		return nil
+	}
+
+	s := &snapshot{Path: name}
+	info, err := os.Stat(name)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if s.Data, err = ioutil.ReadFile(name); err != nil {
+			return err
+		}
+		s.Exists, s.Mode = true, info.Mode()
+	}
+
+	t.files[name] = s
+
+	return nil
+}
+
+// saveVendor moves the vendor directory of the module at root to a backup next to it,
+// instead of snapshotting every vendored file. rollback puts it back, dropping whatever
+// go mod vendor created, commit removes it.
+func (t *txn) saveVendor(root string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dir := filepath.Join(root, "vendor")
+	if t.vendor != nil {
+		// the backup already holds the vendor directory before the upgrade.
+		return nil
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		t.vendor = &dirBackup{path: dir}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// starting with a dot, the go tool ignores it.
+	backup, err := ioutil.TempDir(root, ".vendor.gcu-*")
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(dir, filepath.Join(backup, "vendor")); err != nil {
+		os.Remove(backup)
+		return err
+	}
+
+	t.vendor = &dirBackup{path: dir, backup: backup}
+
+	return nil
+}
+
+// restoreVendor puts the vendor directory back from its backup.
+func (t *txn) restoreVendor() error {
+	if t.vendor == nil {
+		return nil
+	}
+
+	if err := os.RemoveAll(t.vendor.path); err != nil {
+		return err
+	}
+
+	if t.vendor.backup != "" {
+		if err := os.Rename(filepath.Join(t.vendor.backup, "vendor"), t.vendor.path); err != nil {
+			return err
+		}
+
+		if err := os.RemoveAll(t.vendor.backup); err != nil {
+			return err
+		}
+	}
+
+	t.vendor = nil
+
+	return nil
+}
+
+// created records a file the upgrade has created, so it is removed on rollback.
+func (t *txn) created(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.files[name]; !ok {
+		t.files[name] = &snapshot{Path: name}
+	}
+}
+
+// write snapshots the file and atomically replaces its content.
+func (t *txn) write(name string, data []byte) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	err := t.saveLocked(name)
+	t.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode()
+	}
+
+	return writeFileAtomic(name, data, mode)
+}
+
+// rollback puts every touched file back to its snapshot.
+func (t *txn) rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := restoreSnapshots(t.files); err != nil {
+		return err
+	}
+
+	return t.restoreVendor()
+}
+
+// commit remembers the snapshots of a successful upgrade for gcu undo.
+// it refuses to commit after an interrupt, the caller rolls back instead.
+func (t *txn) commit() error {
+	if err := t.check(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	files := make([]*snapshot, 0, len(t.files))
+	for _, s := range t.files {
+		sum, err := fileSum(s.Path)
+		if err != nil {
+			return err
+		}
+		s.Sum = sum
+		files = append(files, s)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	name, err := undoFile(t.root)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(undoRecord{Root: t.root, Time: time.Now(), Files: files, Vendor: t.vendor != nil})
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(name, data, 0644); err != nil {
+		return err
+	}
+
+	if t.vendor != nil && t.vendor.backup != "" {
+		if err := os.RemoveAll(t.vendor.backup); err != nil {
+			return err
+		}
+	}
+	t.vendor = nil
+
+	return nil
+}
+
+// undoRecord is what gcu undo needs to revert the last successful upgrade.
+type undoRecord struct {
+	Root  string      `json:"root"`
+	Time  time.Time   `json:"time"`
+	Files []*snapshot `json:"files"`
+	// Vendor tells undo to regenerate the vendor directory from the restored go.mod.
+	Vendor bool `json:"vendor,omitempty"`
+}
+
+// undo reverts the last successful upgrade of the module at root.
+func undo(root string) (*undoRecord, error) {
+	name, err := undoFile(root)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, errNothingToUndo
+	} else if err != nil {
+		return nil, err
+	}
+
+	rec := new(undoRecord)
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*snapshot, len(rec.Files))
+	for _, s := range rec.Files {
+		sum, err := fileSum(s.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if sum != s.Sum {
+			return nil, fmt.Errorf("%s has changed since the last upgrade, refusing to undo", s.Path)
+		}
+		files[s.Path] = s
+	}
+
+	if err := restoreSnapshots(files); err != nil {
+		return nil, err
+	}
+
+	if rec.Vendor {
+		if err := runGo(rec.Root, "mod", "vendor"); err != nil {
+			return nil, err
+		}
+	}
+
+	return rec, os.Remove(name)
+}
+
+func restoreSnapshots(files map[string]*snapshot) error {
+	var errs []string
+	for _, s := range files {
+		var err error
+		if s.Exists {
+			err = writeFileAtomic(s.Path, s.Data, s.Mode)
+		} else if err = os.Remove(s.Path); os.IsNotExist(err) {
+			err = nil
+		}
+
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("restore: %v", errs)
+	}
+
+	return nil
+}
+
+// undoFile returns where the undo record of the module at root is kept.
+func undoFile(root string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, "gcu", "undo", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// fileSum returns the sha256 of the file, or an empty string if it does not exist.
+func fileSum(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeFileAtomic writes to a temp file next to name and renames it over.
+func writeFileAtomic(name string, data []byte, mode fs.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".gcu-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
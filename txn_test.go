@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxnRollback(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "go.mod")
+	created := filepath.Join(dir, "go.sum")
+	assert.Nil(t, ioutil.WriteFile(kept, []byte("module example.com/m\n"), 0600))
+
+	tx := newTxn(dir)
+	defer tx.close()
+
+	assert.Nil(t, tx.save(kept, created))
+	assert.Nil(t, tx.write(kept, []byte("broken")))
+	assert.Nil(t, tx.write(created, []byte("sum")))
+	assert.Nil(t, tx.rollback())
+
+	data, err := ioutil.ReadFile(kept)
+	assert.Nil(t, err)
+	assert.Equal(t, "module example.com/m\n", string(data))
+
+	info, err := os.Stat(kept)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+
+	_, err = os.Stat(created)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTxnInterrupted(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "go.mod")
+	assert.Nil(t, ioutil.WriteFile(name, []byte("v1"), 0644))
+
+	tx := newTxn(dir)
+	defer tx.close()
+
+	assert.Nil(t, tx.check())
+	assert.Nil(t, tx.write(name, []byte("v2")))
+
+	// Ctrl-C between two steps.
+	atomic.StoreInt32(&tx.stop, 1)
+	assert.Equal(t, errInterrupted, tx.check())
+	assert.Equal(t, errInterrupted, tx.write(name, []byte("v3")))
+	assert.Equal(t, errInterrupted, tx.commit())
+
+	_, err := undo(dir)
+	assert.Equal(t, errNothingToUndo, err)
+}
+
+func TestUndo(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "go.mod")
+	assert.Nil(t, ioutil.WriteFile(name, []byte("v1"), 0644))
+
+	_, err := undo(dir)
+	assert.Equal(t, errNothingToUndo, err)
+
+	tx := newTxn(dir)
+	assert.Nil(t, tx.write(name, []byte("v2")))
+	assert.Nil(t, tx.commit())
+	tx.close()
+
+	assert.Nil(t, ioutil.WriteFile(name, []byte("v3"), 0644))
+	_, err = undo(dir)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(name, []byte("v2"), 0644))
+	rec, err := undo(dir)
+	assert.Nil(t, err)
+	assert.Len(t, rec.Files, 1)
+
+	data, err := ioutil.ReadFile(name)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(data))
+
+	_, err = undo(dir)
+	assert.Equal(t, errNothingToUndo, err)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+type upgradeOptions struct {
+	// rewrite import paths when the major version changes.
+	rewrite bool
+	// run go mod tidy after everything is done.
+	tidy bool
+	// also upgrade the dependencies of the selected modules, like go get -u.
+	update bool
+	// checks to run after upgrading, see verify.
+	verify []string
+	// also rewrite module paths in comments of go files and in the text files
+	// matching textFiles.
+	text      bool
+	textFiles []string
+	// regenerate the vendor directory, set when the module vendors.
+	vendor bool
+	// rename the references to a package whose name changes with the major
+	// version, instead of adding an import alias.
+	renamePkg bool
+}
+
+// result is what an upgrade has done.
+type result struct {
+	// applied are the upgrades left in the tree.
+	applied []version
+	// culprits are the upgrades dropped because they fail the checks.
+	culprits []culprit
+	// replacements are the module paths rewritten outside of the imports.
+	replacements []replacement
+	// rewrite sums up the import rewrite, nil when nothing was rewritten.
+	rewrite *rewriteStats
+	// vendor is what go mod vendor changed, nil when the module does not vendor.
+	vendor *vendorDelta
+}
+
+// plan is the set of upgrades that are applied together in one run.
+type plan struct {
+	versions []version
+	// moved are the upgrades whose module path changes, sorted by
+	// module prefix from the longest to the shortest.
+	moved []version
+}
+
+func newPlan(versions []version) *plan {
+	p := &plan{versions: versions}
+	for _, v := range versions {
+		if v.majorChanged() {
+			p.moved = append(p.moved, v)
+		}
+	}
+
+	// nested modules must be matched before their parents.
+	sort.Slice(p.moved, func(i, j int) bool {
+		return len(p.moved[i].path) > len(p.moved[j].path)
+	})
+
+	return p
+}
+
+// specs returns the module queries passed to go get.
+func (p *plan) specs() []string {
+	specs := make([]string, 0, len(p.versions))
+	for _, v := range p.versions {
+		specs = append(specs, v.newPath()+"@"+v.new)
+	}
+
+	return specs
+}
+
+// replace rewrites an import path of any moved module to its new major version.
+func (p *plan) replace(_ token.Position, path string) (string, error) {
+	for _, v := range p.moved {
+		if _, pkgdir, ok := splitPath(v.path, path); ok {
+			return joinPath(v.path, v.new, pkgdir), nil
+		}
+	}
+
+	return "", filepath.SkipDir
+}
+
+// upgrade moves the given modules to their new versions.
+// go.mod is edited in process and written once, then the go command
+// resolves the module graph in a single run, the imports of all moved
+// modules are rewritten in a single walk and go.mod is tidied once.
+// every touched file is restored if any step fails or the user hits Ctrl-C.
+func upgrade(dir string, versions []version, opts upgradeOptions) (*result, error) {
+	name, err := findModFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Dir(name)
+	t := newTxn(root)
+	defer t.close()
+
+	opts.vendor = vendored(root)
+
+	if err := t.save(name, filepath.Join(root, "go.sum")); err != nil {
+		return nil, err
+	}
+
+	if work, err := findFile(root, "go.work"); err == nil {
+		if err := t.save(work, work+".sum"); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := upgradeTxn(dir, name, versions, t, opts)
+	if err == nil && len(res.applied) > 0 {
+		err = t.commit()
+	}
+
+	if err != nil {
+		if t.interrupted() {
+			err = errInterrupted
+		}
+
+		if rerr := t.rollback(); rerr != nil {
+			return nil, fmt.Errorf("%v, rollback failed: %v", err, rerr)
+		}
+
+		return nil, err
+	}
+
+	if len(res.applied) == 0 {
+		return res, t.rollback()
+	}
+
+	return res, nil
+}
+
+func upgradeTxn(dir, name string, versions []version, t *txn, opts upgradeOptions) (*result, error) {
+	root := filepath.Dir(name)
+
+	res, err := newPlan(versions).apply(dir, name, t, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.verify) == 0 {
+		return res, nil
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	err = verify(root, opts.verify)
+	if err == nil {
+		return res, nil
+	}
+
+	if _, ok := err.(*verifyError); !ok {
+		return nil, err
+	}
+
+	applied, culprits, err := bisect(versions, func(vs []version) error {
+		if err := t.check(); err != nil {
+			return err
+		}
+
+		if err := t.rollback(); err != nil {
+			return err
+		}
+
+		res = &result{}
+		if len(vs) > 0 {
+			var err error
+			if res, err = newPlan(vs).apply(dir, name, t, opts); err != nil {
+				return err
+			}
+		}
+
+		if err := t.check(); err != nil {
+			return err
+		}
+
+		return verify(root, opts.verify)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 {
+		res = &result{}
+	}
+	res.applied, res.culprits = applied, culprits
+
+	return res, nil
+}
+
+func (p *plan) apply(dir, name string, t *txn, opts upgradeOptions) (*result, error) {
+	root := filepath.Dir(name)
+
+	data, err := editModFile(name, p.versions, opts.rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.write(name, data); err != nil {
+		return nil, err
+	}
+
+	args := []string{"get"}
+	if opts.update {
+		args = append(args, "-u")
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	if err := runGo(root, append(args, p.specs()...)...); err != nil {
+		return nil, err
+	}
+
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	res := &result{applied: p.versions}
+	if opts.rewrite && len(p.moved) > 0 {
+		r := p.rewriter(t, opts)
+		if err := r.walk(dir); err != nil {
+			return nil, err
+		}
+
+		res.rewrite = &r.stats
+		if r.text != nil {
+			res.replacements = r.text.replacements
+		}
+	}
+
+	// after rewrite, we need to run go mod tidy to make sure go.mod is valid.
+	if opts.tidy {
+		if err := t.check(); err != nil {
+			return nil, err
+		}
+
+		if err := runGo(root, "mod", "tidy"); err != nil {
+			return nil, err
+		}
+	}
+
+	// or the build fails with inconsistent vendoring.
+	if opts.vendor {
+		if err := t.check(); err != nil {
+			return nil, err
+		}
+
+		if res.vendor, err = revendor(root, t); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// rewriter returns the rewriter moving the imports of the moved modules.
+func (p *plan) rewriter(w fileWriter, opts upgradeOptions) *rewriter {
+	r := &rewriter{replace: p.replace, w: w, names: newPkgNames(p.moved, opts.renamePkg)}
+	for _, v := range p.moved {
+		r.prefixes = append(r.prefixes, []byte(v.path))
+	}
+
+	if opts.text {
+		r.text = newTextReplacer(p.moved, opts.textFiles)
+	}
+
+	return r
+}
+
+// editModFile points the requirements of the given modules to their new versions
+// and returns the new content of go.mod.
+// the old requirement is dropped only when the module path changes and
+// the imports are going to be rewritten, otherwise the code would not build.
+func editModFile(name string, versions []version, rewrite bool) ([]byte, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range versions {
+		newp := v.newPath()
+		if rewrite && v.majorChanged() {
+			if err := f.DropRequire(v.mod); err != nil {
+				return nil, err
+			}
+
+			if err := moveTools(f, v); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := f.AddRequire(newp, v.new); err != nil {
+			return nil, err
+		}
+	}
+
+	f.SortBlocks()
+	f.Cleanup()
+
+	return f.Format()
+}
+
+// moveTools points the tool directives of a module moving to a new major version
+// to the packages of the new version.
+func moveTools(f *modfile.File, v version) error {
+	moved := make([]string, 0)
+	for _, t := range f.Tool {
+		if modpath, _, ok := splitPath(v.path, t.Path); ok && modpath == v.mod {
+			moved = append(moved, t.Path)
+		}
+	}
+
+	for _, path := range moved {
+		_, pkgdir, _ := splitPath(v.path, path)
+		if err := f.DropTool(path); err != nil {
+			return err
+		}
+
+		if err := f.AddTool(joinPath(v.path, v.new, pkgdir)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runGo runs the go command in dir.
+// the error contains what the go command printed to stderr.
+func runGo(dir string, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return fmt.Errorf("go %s: %s", strings.Join(args, " "), msg)
+	}
+
+	return nil
+}
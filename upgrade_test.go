@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditModFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "go.mod")
+	gomod := `module example.com/m
+
+go 1.18
+
+require (
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/google/go-cmp v0.5.8
+	golang.org/x/mod v0.5.1 // indirect
+)
+`
+	assert.Nil(t, ioutil.WriteFile(name, []byte(gomod), 0644))
+
+	versions := []version{
+		{path: "github.com/go-redis/redis", mod: "github.com/go-redis/redis/v8", old: "v8.11.5", new: "v9.0.2"},
+		{path: "github.com/google/go-cmp", mod: "github.com/google/go-cmp", old: "v0.5.8", new: "v0.5.9"},
+	}
+	data, err := editModFile(name, versions, true)
+	assert.Nil(t, err)
+	assert.Equal(t, `module example.com/m
+
+go 1.18
+
+require (
+	github.com/go-redis/redis/v9 v9.0.2
+	github.com/google/go-cmp v0.5.9
+	golang.org/x/mod v0.5.1 // indirect
+)
+`, string(data))
+}
+
+func TestPlanReplace(t *testing.T) {
+	p := newPlan([]version{
+		{path: "github.com/go-redis/redis", mod: "github.com/go-redis/redis/v8", old: "v8.11.5", new: "v9.0.2"},
+		{path: "github.com/google/go-cmp", mod: "github.com/google/go-cmp", old: "v0.5.8", new: "v0.5.9"},
+		{path: "gopkg.in/yaml", mod: "gopkg.in/yaml.v2", old: "v2.4.0", new: "v3.0.1"},
+	})
+	assert.Equal(t, []string{
+		"github.com/go-redis/redis/v9@v9.0.2",
+		"github.com/google/go-cmp@v0.5.9",
+		"gopkg.in/yaml.v3@v3.0.1",
+	}, p.specs())
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"github.com/go-redis/redis/v8", "github.com/go-redis/redis/v9", true},
+		{"github.com/go-redis/redis/v8/internal/pool", "github.com/go-redis/redis/v9/internal/pool", true},
+		{"gopkg.in/yaml.v2", "gopkg.in/yaml.v3", true},
+		{"github.com/google/go-cmp/cmp", "", false},
+		{"github.com/go-redis/redisx", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := p.replace(token.Position{}, tt.path)
+			if !tt.ok {
+				assert.Equal(t, filepath.SkipDir, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// localModule writes a module requiring example.com/dep, replaced by a local directory.
+func localModule(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.22\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ./dep\n",
+		"main.go":        "package main\n\nimport \"example.com/dep\"\n\nfunc main() { dep.A() }\n",
+		"dep/go.mod":     "module example.com/dep\n\ngo 1.22\n",
+		"dep/a.go":       "package dep\n\nfunc A() {}\n",
+		"dep/sub/sub.go": "package sub\n\nfunc B() {}\n",
+	}
+	for name, data := range files {
+		name = filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(data), 0644))
+	}
+
+	return root
+}
+
+// upgradeTest fails once example.com/dep is upgraded to v1.0.0.
+const upgradeTest = `package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	data, _ := os.ReadFile("go.mod")
+	if strings.Contains(string(data), "example.com/dep v1.0.0") {
+		t.Fatal("example.com/dep v1.0.0 is required")
+	}
+}
+`
+
+func TestUpgradeRollback(t *testing.T) {
+	// keep the build cache, the checks build the module.
+	cache, err := exec.Command("go", "env", "GOCACHE").Output()
+	assert.Nil(t, err)
+	t.Setenv("GOCACHE", strings.TrimSpace(string(cache)))
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		name     string
+		versions []version
+		opts     upgradeOptions
+		err      string
+	}{
+		{
+			// the module can't be looked up offline.
+			name:     "go get fails",
+			versions: []version{{path: "example.com/missing", mod: "example.com/missing", old: "v1.0.0", new: "v1.1.0"}},
+			err:      "go get",
+		},
+		{
+			// the replaced module upgrades and is vendored again before the checks fail.
+			name:     "checks fail",
+			versions: []version{{path: "example.com/dep", mod: "example.com/dep", old: "v0.0.0", new: "v1.0.0"}},
+			opts:     upgradeOptions{verify: []string{"test"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := vendorModule(t)
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "main_test.go"), []byte(upgradeTest), 0644))
+			assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "go.sum"), []byte("example.com/missing v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"), 0644))
+
+			snapshot := func() map[string]string {
+				files := make(map[string]string)
+				for _, name := range []string{"go.mod", "go.sum", "vendor/modules.txt"} {
+					data, err := ioutil.ReadFile(filepath.Join(root, name))
+					assert.Nil(t, err)
+					files[name] = string(data)
+				}
+				return files
+			}
+			before := snapshot()
+
+			res, err := upgrade(root, tt.versions, tt.opts)
+			if tt.err != "" {
+				assert.NotNil(t, err)
+				assert.Contains(t, fmt.Sprint(err), tt.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Empty(t, res.applied)
+				assert.Len(t, res.culprits, 1)
+			}
+
+			assert.Equal(t, before, snapshot())
+
+			// nothing to undo and no backup left behind.
+			_, err = undo(root)
+			assert.NotNil(t, err)
+			backups, err := filepath.Glob(filepath.Join(root, ".vendor.gcu-*"))
+			assert.Nil(t, err)
+			assert.Empty(t, backups)
+		})
+	}
+}
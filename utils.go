@@ -1,15 +1,14 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"os/exec"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
 )
 
@@ -28,79 +27,254 @@ func printPartDepLatest() {
 	c.Println("🎉 The dependencies you selected have been updated to the latest!")
 }
 
-func printBye() {
-	c := color.New(color.FgGreen, color.Bold)
-	c.Println("👋 Bye!")
+func printCulprits(res *result) {
+	red := color.New(color.FgRed, color.Bold)
+	for _, c := range res.culprits {
+		red.Printf("✗ %s %s -> %s breaks the checks\n", c.version.path, c.version.oldversion(), c.version.new)
+		fmt.Println(c.err)
+	}
+
+	if len(res.applied) == 0 {
+		red.Println("None of the dependencies you selected have been updated.")
+		return
+	}
+
+	c := color.New(color.FgCyan, color.Bold)
+	c.Println("🎉 The other dependencies you selected have been updated:")
+	for _, v := range res.applied {
+		c.Printf("  %s %s -> %s\n", v.path, v.oldversion(), v.new)
+	}
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
-	} else {
-		return b
+func printRewriteStats(w io.Writer, stats *rewriteStats) {
+	if stats == nil {
+		return
+	}
+
+	c := color.New(color.FgCyan, color.Bold)
+	c.Fprintf(w, "✏️  Imports rewritten in %d files, %d unchanged, %d directories skipped, %d files failed\n",
+		stats.rewritten, stats.unchanged, len(stats.skipped), len(stats.failed))
+
+	yellow := color.New(color.FgYellow)
+	for _, err := range stats.failed {
+		yellow.Fprintf(w, "  warning: %v\n", err)
 	}
 }
 
-func caculateMaxLenForEachItem(versions []version) (m1, m2, m3 int) {
-	for _, v := range versions {
-		m1 = max(m1, len(v.path))
-		m2 = max(m2, len(v.oldversion()))
-		m3 = max(m3, len(v.newVersion()))
+func printReplacements(dir string, replacements []replacement) {
+	if len(replacements) == 0 {
+		return
 	}
 
-	return
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("✏️  Rewrote %d module path references outside of the imports:\n", len(replacements))
+	for _, r := range replacements {
+		name := r.file
+		if rel, err := filepath.Rel(dir, r.file); err == nil {
+			name = rel
+		}
+		fmt.Printf("  %s:%d: %s -> %s\n", name, r.line, r.old, r.new)
+	}
 }
 
-type MultiSelect struct {
-	survey.MultiSelect
+func printVendorDelta(delta *vendorDelta) {
+	if delta == nil {
+		return
+	}
+
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("📦 Vendor directory regenerated: %d files added, %d removed, %d changed\n", delta.added, delta.removed, delta.changed)
 }
 
-func (m MultiSelect) Cleanup(config *survey.PromptConfig, val interface{}) error {
-	return m.Render("", nil)
+func printUndone(rec *undoRecord) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("↩️  Reverted %d files to before the upgrade at %s\n", len(rec.Files), rec.Time.Format(time.RFC1123))
 }
 
-func checkBinaries(fp string) error {
-	s := spinner.New(spinner.CharSets[0], 100*time.Millisecond)
-	s.Prefix = "Updating... Please wait. "
-	if err := s.Color("cyan"); err != nil {
-		return err
+func printNoBreakingChanges(v version) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("🎉 No breaking API changes between %s %s and %s!\n", v.path, v.oldversion(), v.new)
+}
+
+func printChangelogTitle(v version) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("📝 %s %s -> %s\n\n", v.path, v.oldversion(), v.new)
+}
+
+func printLicenseChange(c *licenseChange, allowed bool) {
+	c1 := color.New(color.FgYellow, color.Bold)
+	if !allowed {
+		c1 = color.New(color.FgRed, color.Bold)
+	}
+	if c.err != nil {
+		c1.Printf("⚠️  %s %s -> %s: can't check its license: %v\n", c.version.path, c.version.oldversion(), c.version.new, c.err)
+		return
 	}
+	c1.Printf("⚠️  %s %s -> %s changes its license: %s\n", c.version.path, c.version.oldversion(), c.version.new, c)
+}
+
+// licenseColumn is the license warning of a dependency in the list table.
+func licenseColumn(c *licenseChange, cfg *config) string {
+	switch {
+	case c.err != nil:
+		return color.RedString("⚠ unknown")
+	case !c.changed && c.new == "":
+		return "none"
+	case !c.changed:
+		return c.new
+	case c.allowed(cfg):
+		return color.YellowString("⚠ " + c.String())
+	default:
+		return color.RedString("⚠ " + c.String())
+	}
+}
+
+func printVulnDBError(err error) {
+	c := color.New(color.FgYellow)
+	c.Printf("warning: can't check vulnerabilities: %v\n", err)
+}
 
-	s.Start()
+// printLookupError warns about a binary whose latest version can't be looked up.
+func printLookupError(err error) {
+	c := color.New(color.FgYellow)
+	c.Printf("warning: can't check %v\n", err)
+}
 
-	defer func() {
-		s.Stop()
-		printAllLibLatest()
-	}()
+func printNoVulns() {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Println("🎉 No known vulnerabilities with a fix in your dependencies!")
+}
 
-	output, err := exec.Command("go", "version", "-m", fp).Output()
-	if err != nil {
-		return err
+// vulnColumn is the vulnerabilities of a dependency in the list table.
+func vulnColumn(r *vulnReport) string {
+	if r == nil {
+		return "none"
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(output))
-	paths := make([]string, 0)
-	wg := sync.WaitGroup{}
+	return color.RedString("⚠ " + r.String())
+}
+
+func printNoCompatibleVersion(maxGo string) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("🎉 No upgrade compatible with go %s!\n", maxGo)
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "path") {
-			path := strings.SplitN(line, "\t", 2)[1]
-			paths = append(paths, path)
+// printBlockedFix warns about a vulnerable dependency whose fixing versions all need a newer go.
+func printBlockedFix(v version, maxGo string) {
+	c := color.New(color.FgRed, color.Bold)
+	c.Printf("⚠️  %s %s is vulnerable (%s) but no version from %s on is compatible with go %s\n", v.path, v.oldversion(), strings.Join(v.vuln.ids, ", "), v.vuln.fix, maxGo)
+}
 
+// goColumn is the go line of the new version of a dependency in the list table.
+func goColumn(d *goDirectives) string {
+	switch {
+	case d == nil:
+		return "?"
+	case d.newer:
+		return color.YellowString("⚠ " + d.String())
+	default:
+		return d.String()
+	}
+}
+
+func printGoLatest(current string) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("🎉 go %s is the latest!\n", current)
+}
+
+func printGoUpgraded(release string) {
+	c := color.New(color.FgCyan, color.Bold)
+	c.Printf("🎉 The go directives have been updated to go %s!\n", release)
+}
+
+// printInstallSummary reports each binary upgrade, with the go install output of the failed ones.
+func printInstallSummary(results []installResult) {
+	dirs := make(map[string]bool)
+	for _, r := range results {
+		dirs[r.bin.dir] = true
+	}
+
+	for i, r := range results {
+		// grouped by directory, like --path-scan finds them.
+		if len(dirs) > 1 && (i == 0 || results[i-1].bin.dir != r.bin.dir) {
+			color.New(color.Bold).Println(r.bin.dir)
+		}
+
+		to := r.bin.new
+		switch {
+		case r.bin.kind == kindDevel:
+			to = "revision " + shortRevision(r.bin.settings.revision)
+		case r.bin.majorChanged():
+			to = r.bin.installPath()
+		}
+
+		if r.err == nil {
+			color.Green("✔ %s %s -> %s", r.bin.name, r.bin.oldversion(), to)
+			continue
+		}
+
+		color.Red("✘ %s %s -> %s: %v", r.bin.name, r.bin.oldversion(), to, r.err)
+		for _, line := range strings.Split(strings.TrimSpace(string(r.output)), "\n") {
+			if line != "" {
+				fmt.Println("    " + line)
+			}
 		}
 	}
+}
+
+// printSkippedBinaries explains why the binaries go install can't reproduce are not upgraded.
+func printSkippedBinaries(bins []binary) {
+	for _, b := range bins {
+		color.Yellow("- skipped %s (%s) in %s: %s", b.name, b.kind, b.dir, b.skipReason())
+	}
+}
+
+func printBye() {
+	c := color.New(color.FgGreen, color.Bold)
+	c.Println("👋 Bye!")
+}
 
-	wg.Add(len(paths))
+// parallel calls fn for every index below n, with at most GOMAXPROCS calls at a time.
+func parallel(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
 
-	for _, path := range paths {
-		go func(path string) {
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
 			defer wg.Done()
-			_ = exec.Command("go", "install", path+"@latest").Run()
-		}(path)
+			for i := range jobs {
+				fn(i)
+			}
+		}()
 	}
 
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+
 	wg.Wait()
+}
 
-	return nil
+func max(a, b int) int {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
+
+func caculateMaxLenForEachItem(versions []version) (m1, m2, m3 int) {
+	for _, v := range versions {
+		m1 = max(m1, len(v.path))
+		m2 = max(m2, len(v.oldversion()))
+		m3 = max(m3, len(v.newVersion()))
+	}
+
+	return
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// vendorDelta counts the vendored files changed by go mod vendor.
+type vendorDelta struct {
+	added   int
+	removed int
+	changed int
+}
+
+// vendored reports whether the module at root vendors its dependencies:
+// vendor/modules.txt exists or -mod=vendor is in GOFLAGS.
+func vendored(root string) bool {
+	if _, err := os.Stat(filepath.Join(root, "vendor", "modules.txt")); err == nil {
+		return true
+	}
+
+	cmd := exec.Command("go", "env", "GOFLAGS")
+	cmd.Dir = root
+
+	flags, err := cmd.Output()
+	if err != nil {
+		flags = []byte(os.Getenv("GOFLAGS"))
+	}
+
+	for _, flag := range strings.Fields(string(flags)) {
+		if flag == "-mod=vendor" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// vendorFiles returns the sha256 of every file in the vendor directory, by relative path.
+func vendorFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		files[rel] = hex.EncodeToString(sum[:])
+
+		return nil
+	})
+
+	return files, err
+}
+
+// revendor regenerates the vendor directory after an upgrade.
+// the vendor directory is moved aside by the transaction, so a rollback brings
+// it back as it was, without the files and directories go mod vendor created.
+func revendor(root string, t *txn) (*vendorDelta, error) {
+	dir := filepath.Join(root, "vendor")
+	before, err := vendorFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.saveVendor(root); err != nil {
+		return nil, err
+	}
+
+	if err := runGo(root, "mod", "vendor"); err != nil {
+		return nil, err
+	}
+
+	after, err := vendorFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &vendorDelta{}
+	for name, sum := range after {
+		old, ok := before[name]
+		switch {
+		case !ok:
+			delta.added++
+		case old != sum:
+			delta.changed++
+		}
+	}
+
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			delta.removed++
+		}
+	}
+
+	return delta, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vendorModule writes a module vendoring example.com/dep, replaced by a local directory.
+func vendorModule(t *testing.T) string {
+	root := localModule(t)
+	assert.Nil(t, runGo(root, "mod", "vendor"))
+
+	return root
+}
+
+func TestRevendorRollback(t *testing.T) {
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	root := vendorModule(t)
+	modules, err := ioutil.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	assert.Nil(t, err)
+
+	// the upgrade starts using a new package of the dependency.
+	main := filepath.Join(root, "main.go")
+	src := "package main\n\nimport (\n\t\"example.com/dep\"\n\t\"example.com/dep/sub\"\n)\n\nfunc main() { dep.A(); sub.B() }\n"
+
+	tx := newTxn(root)
+	defer tx.close()
+
+	assert.Nil(t, tx.write(main, []byte(src)))
+	delta, err := revendor(root, tx)
+	assert.Nil(t, err)
+	assert.Equal(t, &vendorDelta{added: 1, changed: 1}, delta)
+
+	sub := filepath.Join(root, "vendor", "example.com", "dep", "sub")
+	_, err = os.Stat(sub)
+	assert.Nil(t, err)
+
+	assert.Nil(t, tx.rollback())
+
+	_, err = os.Stat(sub)
+	assert.True(t, os.IsNotExist(err))
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(modules), string(data))
+
+	// no backup is left behind.
+	backups, err := filepath.Glob(filepath.Join(root, ".vendor.gcu-*"))
+	assert.Nil(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestRevendorUndo(t *testing.T) {
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	root := vendorModule(t)
+	modules, err := ioutil.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	assert.Nil(t, err)
+
+	main := filepath.Join(root, "main.go")
+	src := "package main\n\nimport (\n\t\"example.com/dep\"\n\t\"example.com/dep/sub\"\n)\n\nfunc main() { dep.A(); sub.B() }\n"
+
+	tx := newTxn(root)
+	assert.Nil(t, tx.write(main, []byte(src)))
+	_, err = revendor(root, tx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.commit())
+	tx.close()
+
+	backups, err := filepath.Glob(filepath.Join(root, ".vendor.gcu-*"))
+	assert.Nil(t, err)
+	assert.Empty(t, backups)
+
+	// the vendored files are not part of the undo record.
+	rec, err := undo(root)
+	assert.Nil(t, err)
+	assert.True(t, rec.Vendor)
+	assert.Len(t, rec.Files, 1)
+
+	_, err = os.Stat(filepath.Join(root, "vendor", "example.com", "dep", "sub"))
+	assert.True(t, os.IsNotExist(err))
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, string(modules), string(data))
+}
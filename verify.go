@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// checks maps the --verify values to the go command run for them.
+var checks = map[string][]string{
+	"build": {"build", "./..."},
+	"vet":   {"vet", "./..."},
+	"test":  {"test", "./..."},
+}
+
+// parseChecks parses a comma separated list of checks, like "build,test".
+func parseChecks(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := checks[name]; !ok {
+			return nil, fmt.Errorf("unknown check %q, must be one of build, vet, test", name)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// verifyError is returned when a check fails, it keeps what the go command printed.
+type verifyError struct {
+	check  string
+	output string
+}
+
+func (e *verifyError) Error() string {
+	return fmt.Sprintf("go %s failed:\n%s", e.check, e.output)
+}
+
+// verify runs the given checks in the module root and stops at the first failure.
+func verify(root string, names []string) error {
+	for _, name := range names {
+		cmd := exec.Command("go", checks[name]...)
+		cmd.Dir = root
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			msg := strings.TrimSpace(string(output))
+			if msg == "" {
+				msg = err.Error()
+			}
+
+			return &verifyError{check: name, output: msg}
+		}
+	}
+
+	return nil
+}
+
+// culprit is an upgrade that makes the checks fail.
+type culprit struct {
+	version version
+	err     error
+}
+
+// bisect finds the upgrades that break the checks.
+// try applies the given upgrades on top of the original tree and verifies them.
+// the culprits are found one at a time by searching for the shortest failing
+// prefix of the remaining upgrades, the last element of that prefix is the
+// culprit. it returns the upgrades that pass together, which are left applied,
+// when nothing passes the caller is left to restore the original tree.
+func bisect(versions []version, try func([]version) error) ([]version, []culprit, error) {
+	if err := try(nil); err != nil {
+		return nil, nil, fmt.Errorf("checks fail without any upgrade: %w", err)
+	}
+
+	rest := append([]version(nil), versions...)
+	culprits := make([]culprit, 0)
+
+	for len(rest) > 0 {
+		err := try(rest)
+		if err == nil {
+			return rest, culprits, nil
+		}
+
+		if _, ok := err.(*verifyError); !ok {
+			return nil, nil, err
+		}
+
+		// rest[:hi] fails, rest[:lo-1] passes.
+		lo, hi := 1, len(rest)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if e := try(rest[:mid]); e != nil {
+				if _, ok := e.(*verifyError); !ok {
+					return nil, nil, e
+				}
+				hi, err = mid, e
+			} else {
+				lo = mid + 1
+			}
+		}
+
+		culprits = append(culprits, culprit{version: rest[hi-1], err: err})
+		rest = append(rest[:hi-1], rest[hi:]...)
+	}
+
+	return nil, culprits, nil
+}
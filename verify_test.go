@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBisect(t *testing.T) {
+	versions := []version{
+		{path: "a"}, {path: "b"}, {path: "c"}, {path: "d"}, {path: "e"},
+	}
+
+	tests := []struct {
+		name     string
+		broken   map[string]bool
+		applied  []string
+		culprits []string
+	}{
+		{"none", map[string]bool{}, []string{"a", "b", "c", "d", "e"}, []string{}},
+		{"one", map[string]bool{"c": true}, []string{"a", "b", "d", "e"}, []string{"c"}},
+		{"two", map[string]bool{"a": true, "e": true}, []string{"b", "c", "d"}, []string{"a", "e"}},
+		{"all", map[string]bool{"a": true, "b": true, "c": true, "d": true, "e": true}, []string{}, []string{"a", "b", "c", "d", "e"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, culprits, err := bisect(versions, func(vs []version) error {
+				for _, v := range vs {
+					if tt.broken[v.path] {
+						return &verifyError{check: "build", output: v.path}
+					}
+				}
+				return nil
+			})
+			assert.Nil(t, err)
+
+			paths := make([]string, 0)
+			for _, v := range applied {
+				paths = append(paths, v.path)
+			}
+			assert.Equal(t, tt.applied, paths)
+
+			paths = make([]string, 0)
+			for _, c := range culprits {
+				paths = append(paths, c.version.path)
+				assert.Contains(t, c.err.Error(), c.version.path)
+			}
+			assert.Equal(t, tt.culprits, paths)
+		})
+	}
+}
+
+func TestParseChecks(t *testing.T) {
+	names, err := parseChecks("build, test")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "test"}, names)
+
+	_, err = parseChecks("lint")
+	assert.NotNil(t, err)
+}
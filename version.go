@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os/exec"
 	"regexp"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,8 +17,16 @@ import (
 
 type version struct {
 	path string
-	old  string
-	new  string
+	// mod is the module path currently required in go.mod.
+	mod string
+	old string
+	new string
+	// vuln are the known vulnerabilities of the current version, nil if there are none.
+	vuln *vulnReport
+	// goMod is the go line of the new version, nil if unknown.
+	goMod *goDirectives
+	// tool reports whether the module backs a tool directive or a tools.go import.
+	tool bool
 }
 
 // if v1 != v2 diff will returns true else false.
@@ -54,9 +63,30 @@ func (v *version) newVersion() string {
 	return fmt.Sprintf("%s.%s.%s%s", major(news[1]), minor(news[2]), patch(news[3]), pre(news[4]))
 }
 
+// newPath returns the module path of the new version.
+func (v *version) newPath() string {
+	return joinPath(v.path, v.new, "")
+}
+
+// majorChanged reports whether the new version lives at another module path.
+func (v *version) majorChanged() bool {
+	return v.mod != v.newPath()
+}
+
 func (v *version) String(m1, m2, m3 int) string {
 	format := fmt.Sprintf("%%-%ds %%-%ds %%-%ds", m1, m2, m3)
-	return fmt.Sprintf(format, v.path, v.oldversion(), v.newVersion())
+	s := fmt.Sprintf(format, v.path, v.oldversion(), v.newVersion())
+	if v.vuln != nil {
+		s += color.RedString(" ⚠ %s", v.vuln)
+	}
+	if v.tool {
+		s += color.CyanString(" (tool)")
+	}
+	if v.goMod != nil && v.goMod.newer {
+		s += color.YellowString(" ⚠ needs go %s", v.goMod.goVersion)
+	}
+
+	return s
 }
 
 func getVersions(ctx cli.Context, fp string) ([]version, error) {
@@ -75,7 +105,7 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 	mu := &sync.Mutex{}
 
 	// before we get direct dependencies, we need to run go mod tidy ?
-	if ctx.Bool("tidy") {
+	if ctx.Bool("tidy") && !ctx.Bool("dry-run") {
 		if err := exec.Command("go", "mod", "tidy").Run(); err != nil {
 			return nil, errCanNotFindGoModFile
 		}
@@ -86,6 +116,23 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 		return nil, err
 	}
 
+	_, toolMods, err := toolRequirements(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	isTool := make(map[string]bool, len(toolMods))
+	for _, mod := range toolMods {
+		isTool[mod.Path] = true
+	}
+
+	// the requirements of tool directives are indirect.
+	for _, mod := range toolMods {
+		if !requires(deps, mod.Path) {
+			deps = append(deps, mod)
+		}
+	}
+
 	versions := make([]version, 0, len(deps))
 
 	pattern := regexp.MustCompile(`v0.0.0-.+`)
@@ -94,8 +141,9 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 	wgCmd.Add(1)
 	go func() {
 		defer wgCmd.Done()
+		// the requirements of tool directives are indirect, only deps are looked up below.
 		output, _ = exec.Command("go", "list", "-u",
-			"-f", "'{{if (and (not (or .Main .Indirect)) .Update)}}{{.Path}}: [{{.Version}}] [{{.Update.Version}}]{{end}}'",
+			"-f", "'{{if (and (not .Main) .Update)}}{{.Path}}: [{{.Version}}] [{{.Update.Version}}]{{end}}'",
 			"-m", "all").Output()
 	}()
 	if err != nil {
@@ -111,7 +159,7 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 				wgCmd.Wait()
 
 				old := dep.Version
-				extractPattern := regexp.MustCompile(dep.Path + `: \[.*]\ \[(.*)\]`)
+				extractPattern := regexp.MustCompile(`(?m)^'` + regexp.QuoteMeta(dep.Path) + `: \[.*]\ \[(.*)\]`)
 				result := extractPattern.FindStringSubmatch(string(output))
 				if len(result) != 2 {
 					return
@@ -120,8 +168,10 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 				mu.Lock()
 				versions = append(versions, version{
 					path: modPrefix(dep.Path),
+					mod:  dep.Path,
 					old:  old,
 					new:  new,
+					tool: isTool[dep.Path],
 				})
 				mu.Unlock()
 
@@ -137,8 +187,10 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 				mu.Lock()
 				versions = append(versions, version{
 					path: modPrefix(mod.Path),
+					mod:  dep.Path,
 					old:  old,
 					new:  new,
+					tool: isTool[dep.Path],
 				})
 				mu.Unlock()
 			}
@@ -147,5 +199,21 @@ func getVersions(ctx cli.Context, fp string) ([]version, error) {
 
 	wg.Wait()
 
+	// tools are a group of their own, after the other dependencies.
+	sort.SliceStable(versions, func(i, j int) bool {
+		return !versions[i].tool && versions[j].tool
+	})
+
 	return versions, nil
 }
+
+// requires reports whether the module path is one of the requirements.
+func requires(mods []module.Version, path string) bool {
+	for _, mod := range mods {
+		if mod.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
 )
 
 func TestDiff(t *testing.T) {
@@ -53,3 +61,52 @@ func TestDiff(t *testing.T) {
 		}
 	}
 }
+
+func TestGetVersionsSafeTool(t *testing.T) {
+	files := map[string]string{
+		"/example.com/tool/@v/list":        "v1.0.0\nv1.1.0\n",
+		"/example.com/tool/@v/v1.0.0.info": `{"Version":"v1.0.0"}`,
+		"/example.com/tool/@v/v1.1.0.info": `{"Version":"v1.1.0"}`,
+		"/example.com/tool/@v/v1.0.0.mod":  "module example.com/tool\n\ngo 1.22\n",
+		"/example.com/tool/@v/v1.1.0.mod":  "module example.com/tool\n\ngo 1.22\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.Error(w, "not found: "+r.URL.Path, http.StatusGone)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GOPROXY", srv.URL)
+	t.Setenv("GOFLAGS", "-mod=mod -modcacherw")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOTOOLCHAIN", "local")
+
+	root := t.TempDir()
+	gomod := "module example.com/m\n\ngo 1.24\n\ntool example.com/tool\n\nrequire example.com/tool v1.0.0 // indirect\n"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte(gomod), 0644))
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(root))
+	defer func() {
+		assert.Nil(t, os.Chdir(wd))
+	}()
+
+	// --safe looks the updates up with go list, which marks tool requirements indirect.
+	var got []version
+	app := newApp()
+	app.Action = func(ctx *cli.Context) error {
+		got, err = getVersions(*ctx, root)
+		return err
+	}
+	assert.Nil(t, app.Run([]string{"gcu", "--safe", "--tidy=false"}))
+	assert.Equal(t, []version{
+		{path: "example.com/tool", mod: "example.com/tool", old: "v1.0.0", new: "v1.1.0", tool: true},
+	}, got)
+}
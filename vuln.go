@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// defaultVulnDB is the Go vulnerability database, like GOVULNDB of govulncheck.
+const defaultVulnDB = "https://vuln.go.dev"
+
+// osvEntry is a vulnerability report in the OSV format, only the fields we use.
+type osvEntry struct {
+	ID       string        `json:"id"`
+	Aliases  []string      `json:"aliases"`
+	Summary  string        `json:"summary"`
+	Affected []osvAffected `json:"affected"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Ranges []osvRange `json:"ranges"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced string `json:"introduced,omitempty"`
+	Fixed      string `json:"fixed,omitempty"`
+}
+
+// vulnDB reads a vulnerability database laid out like vuln.go.dev:
+// index/modules.json lists the vulnerabilities of each module and ID/<id>.json are the reports.
+type vulnDB struct {
+	// src is a directory, a file:// or an http(s) URL.
+	src string
+	// modules are the ids of the vulnerabilities of each module path.
+	modules map[string][]string
+
+	mu      sync.Mutex
+	entries map[string]*osvEntry
+}
+
+// vulnIndexTTL is how long the module index of a remote database is reused.
+const vulnIndexTTL = time.Hour
+
+// openVulnDB loads the module index of the database at src.
+func openVulnDB(src string) (*vulnDB, error) {
+	db := &vulnDB{src: strings.TrimSuffix(src, "/"), entries: make(map[string]*osvEntry)}
+
+	data, err := db.index()
+	if err != nil {
+		return nil, err
+	}
+
+	var index []struct {
+		Path  string `json:"path"`
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("vulndb %s: %v", src, err)
+	}
+
+	db.modules = make(map[string][]string, len(index))
+	for _, m := range index {
+		for _, v := range m.Vulns {
+			db.modules[m.Path] = append(db.modules[m.Path], v.ID)
+		}
+	}
+
+	return db, nil
+}
+
+// remote reports whether the database is read over http(s).
+func (db *vulnDB) remote() bool {
+	u, err := url.Parse(db.src)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// read returns the file of the database at the slash separated path rel.
+func (db *vulnDB) read(rel string) ([]byte, error) {
+	if u, err := url.Parse(db.src); err != nil || len(u.Scheme) <= 1 {
+		// a local directory, or a windows path like C:\vulndb.
+		return fetch(filepath.Join(db.src, filepath.FromSlash(rel)))
+	}
+
+	return fetch(db.src + "/" + rel)
+}
+
+// index returns the module index of the database. the index of a remote database
+// is cached for vulnIndexTTL, and the cached one is used when it can't be fetched.
+func (db *vulnDB) index() ([]byte, error) {
+	const rel = "index/modules.json"
+	if !db.remote() {
+		return db.read(rel)
+	}
+
+	name, err := vulnIndexFile(db.src)
+	if err != nil {
+		return db.read(rel)
+	}
+
+	if st, err := os.Stat(name); err == nil && time.Since(st.ModTime()) < vulnIndexTTL {
+		if data, err := ioutil.ReadFile(name); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := db.read(rel)
+	if err != nil {
+		if cached, cerr := ioutil.ReadFile(name); cerr == nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	// the cache is best effort.
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err == nil {
+		_ = writeFileAtomic(name, data, 0644)
+	}
+
+	return data, nil
+}
+
+// vulnIndexFile returns where the module index of the database at src is cached.
+func vulnIndexFile(src string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(src))
+	return filepath.Join(dir, "gcu", "vulndb", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// entry returns the report of the vulnerability id.
+func (db *vulnDB) entry(id string) (*osvEntry, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if e, ok := db.entries[id]; ok {
+		return e, nil
+	}
+
+	data, err := db.read("ID/" + id + ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	e := new(osvEntry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("vulndb %s: %v", id, err)
+	}
+	db.entries[id] = e
+
+	return e, nil
+}
+
+// vulns returns the vulnerabilities affecting the version of the module.
+func (db *vulnDB) vulns(modp, ver string) ([]*osvEntry, error) {
+	vulns := make([]*osvEntry, 0)
+	for _, id := range db.modules[modp] {
+		e, err := db.entry(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.affects(modp, ver) {
+			vulns = append(vulns, e)
+		}
+	}
+
+	return vulns, nil
+}
+
+// affects reports whether the version of the module is in an affected range of the report.
+func (e *osvEntry) affects(modp, ver string) bool {
+	for _, a := range e.Affected {
+		if a.Package.Name != modp {
+			continue
+		}
+
+		for _, r := range a.Ranges {
+			if r.Type == "SEMVER" && r.contains(ver) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// contains reports whether the version is between an introduced and a fixed event of the range.
+func (r *osvRange) contains(ver string) bool {
+	events := append([]osvEvent(nil), r.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return semver.Compare(events[i].version(), events[j].version()) < 0
+	})
+
+	affected := false
+	for _, e := range events {
+		if !affected && e.Introduced != "" {
+			affected = e.Introduced == "0" || semver.Compare(ver, e.version()) >= 0
+		} else if affected && e.Fixed != "" {
+			affected = semver.Compare(ver, e.version()) < 0
+		}
+	}
+
+	return affected
+}
+
+// version returns the canonical version of the event, introduced "0" sorts first.
+func (e *osvEvent) version() string {
+	v := e.Introduced + e.Fixed
+	if v == "0" {
+		return "v0.0.0-00000000000000-000000000000"
+	}
+
+	return "v" + v
+}
+
+// vulnReport is what the vulnerability database knows about a dependency.
+type vulnReport struct {
+	// ids of the vulnerabilities of the current version.
+	ids []string
+	// fix is the lowest version fixing all of them, empty if there is none.
+	fix string
+	// remaining are the vulnerabilities of the target version.
+	remaining []string
+}
+
+func (r *vulnReport) String() string {
+	s := strings.Join(r.ids, ", ")
+	if r.fix == "" {
+		s += ", no fix"
+	} else {
+		s += ", fixed in " + r.fix
+	}
+
+	if len(r.remaining) > 0 {
+		s += ", target affected by " + strings.Join(r.remaining, ", ")
+	}
+
+	return s
+}
+
+// report checks the current version of the dependency and the candidate versions,
+// the versions of the same module, for the lowest one fixing its vulnerabilities.
+// it returns nil if the current version isn't affected.
+func (db *vulnDB) report(v version, candidates []string, stable bool) (*vulnReport, error) {
+	vulns, err := db.vulns(v.mod, v.old)
+	if err != nil || len(vulns) == 0 {
+		return nil, err
+	}
+
+	r := &vulnReport{ids: entryIDs(vulns)}
+
+	sorted := append([]string(nil), candidates...)
+	semver.Sort(sorted)
+
+candidates:
+	for _, c := range sorted {
+		if semver.Compare(c, v.old) <= 0 || stable && semver.Prerelease(c) != "" {
+			continue
+		}
+
+		for _, e := range vulns {
+			if e.affects(v.mod, c) {
+				continue candidates
+			}
+		}
+
+		r.fix = c
+		break
+	}
+
+	return r, nil
+}
+
+func entryIDs(entries []*osvEntry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+
+	return ids
+}
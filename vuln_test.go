@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeVulnDB writes a vulnerability database with two reports on example.com/foo.
+func writeVulnDB(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index/modules.json": `[
+			{"path": "example.com/foo", "vulns": [{"id": "GO-2022-0001"}, {"id": "GO-2022-0002"}]},
+			{"path": "example.com/foo/v2", "vulns": [{"id": "GO-2022-0003"}]}
+		]`,
+		"ID/GO-2022-0001.json": `{"id": "GO-2022-0001", "affected": [{"package": {"name": "example.com/foo", "ecosystem": "Go"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]}]}`,
+		"ID/GO-2022-0002.json": `{"id": "GO-2022-0002", "affected": [{"package": {"name": "example.com/foo", "ecosystem": "Go"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "1.1.0"}, {"fixed": "1.3.1"}, {"introduced": "1.5.0"}]}]}]}`,
+		"ID/GO-2022-0003.json": `{"id": "GO-2022-0003", "affected": [{"package": {"name": "example.com/foo/v2", "ecosystem": "Go"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "2.0.0"}, {"fixed": "2.0.1"}]}]}]}`,
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func TestVulnDB(t *testing.T) {
+	dir := writeVulnDB(t)
+
+	for _, src := range []string{dir, "file://" + filepath.ToSlash(dir)} {
+		db, err := openVulnDB(src)
+		assert.Nil(t, err)
+
+		tests := []struct {
+			mod, ver string
+			want     []string
+		}{
+			{"example.com/foo", "v1.0.0", []string{"GO-2022-0001"}},
+			{"example.com/foo", "v1.1.5", []string{"GO-2022-0001", "GO-2022-0002"}},
+			{"example.com/foo", "v1.2.0", []string{"GO-2022-0002"}},
+			{"example.com/foo", "v1.4.0", []string{}},
+			{"example.com/foo", "v1.5.0", []string{"GO-2022-0002"}},
+			{"example.com/foo/v2", "v2.0.0", []string{"GO-2022-0003"}},
+			{"example.com/bar", "v1.0.0", []string{}},
+		}
+
+		for _, tt := range tests {
+			vulns, err := db.vulns(tt.mod, tt.ver)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, entryIDs(vulns), "%s@%s", tt.mod, tt.ver)
+		}
+	}
+}
+
+func TestVulnReport(t *testing.T) {
+	db, err := openVulnDB(writeVulnDB(t))
+	assert.Nil(t, err)
+
+	candidates := []string{"v1.0.0", "v1.1.5", "v1.2.0", "v1.3.1", "v1.4.0-rc.1", "v1.4.0", "v1.5.0"}
+	v := version{path: "example.com/foo", mod: "example.com/foo", old: "v1.1.5", new: "v1.5.0"}
+
+	r, err := db.report(v, candidates, true)
+	assert.Nil(t, err)
+	assert.Equal(t, &vulnReport{ids: []string{"GO-2022-0001", "GO-2022-0002"}, fix: "v1.3.1"}, r)
+
+	v.old = "v1.4.0"
+	r, err = db.report(v, candidates, true)
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	v.old = "v1.5.0"
+	r, err = db.report(v, candidates, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "GO-2022-0002, no fix", r.String())
+}
+
+func TestVulnDBIndexCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	files := http.FileServer(http.Dir(writeVulnDB(t)))
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	db, err := openVulnDB(srv.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"GO-2022-0003"}, db.modules["example.com/foo/v2"])
+	assert.Equal(t, 1, requests)
+
+	// the index is reused while it is fresh.
+	_, err = openVulnDB(srv.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+
+	// a stale index is fetched again, or used as it is when the database can't be reached.
+	name, err := vulnIndexFile(srv.URL)
+	assert.Nil(t, err)
+	old := time.Now().Add(-2 * vulnIndexTTL)
+	assert.Nil(t, os.Chtimes(name, old, old))
+
+	_, err = openVulnDB(srv.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, requests)
+
+	assert.Nil(t, os.Chtimes(name, old, old))
+	srv.Close()
+
+	db, err = openVulnDB(srv.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"GO-2022-0003"}, db.modules["example.com/foo/v2"])
+}